@@ -0,0 +1,100 @@
+package codeowners
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Resolver selects the reviewers of a file from the owners of its rule in a Bitbucket file,
+// expanding groups and applying the selection strategy of each owner. Selections are
+// deterministic: random ones depend only on the seed and the sequence of calls, and ties between
+// equally busy owners are broken by name. A Resolver is not safe for concurrent use.
+type Resolver struct {
+	// Groups are the members of each group, as defined in the file and returned by WithGroups.
+	Groups map[string][]Owner
+	// OpenReviews, if set, returns the number of open reviews of an owner, for least_busy. By
+	// default every owner is equally busy.
+	OpenReviews func(owner Owner) int
+
+	rand *rand.Rand
+}
+
+// NewResolver returns a Resolver for the groups, whose random selections are seeded with seed.
+func NewResolver(groups map[string][]Owner, seed int64) *Resolver {
+	return &Resolver{Groups: groups, rand: rand.New(rand.NewSource(seed))}
+}
+
+// Reviewers returns the reviewers selected from the owners, with the selections of their rule.
+// Consecutive owners with the same selection strategy are selected from together, so
+// "random(2) @@Backend @@Frontend" picks two reviewers from the members of both groups.
+func (r *Resolver) Reviewers(owners []Owner, selections map[Owner]Selection) ([]Owner, error) {
+	reviewers := make([]Owner, 0, len(owners))
+	for start := 0; start < len(owners); {
+		end := start + 1
+		for end < len(owners) && selections[owners[end]] == selections[owners[start]] {
+			end++
+		}
+
+		candidates := make([]Owner, 0, end-start)
+		for _, owner := range owners[start:end] {
+			members, err := r.members(owner, nil)
+			if err != nil {
+				return nil, err
+			}
+			candidates = unionOwners(candidates, members)
+		}
+		reviewers = unionOwners(reviewers, r.selectReviewers(selections[owners[start]], candidates))
+		start = end
+	}
+	return reviewers, nil
+}
+
+// members returns the users an owner stands for, expanding groups. stack lists the groups being
+// expanded, to detect cycles.
+func (r *Resolver) members(owner Owner, stack []string) ([]Owner, error) {
+	if owner.Type != GroupOwner {
+		return []Owner{owner}, nil
+	}
+
+	if containsString(stack, owner.Value) {
+		return nil, fmt.Errorf("group cycle: @@%s", strings.Join(append(stack, owner.Value), " -> @@"))
+	}
+	group, ok := r.Groups[owner.Value]
+	if !ok {
+		return nil, fmt.Errorf("group @@%s is not defined", owner.Value)
+	}
+
+	stack = append(stack, owner.Value)
+	members := make([]Owner, 0, len(group))
+	for _, member := range group {
+		expanded, err := r.members(member, stack)
+		if err != nil {
+			return nil, err
+		}
+		members = unionOwners(members, expanded)
+	}
+	return members, nil
+}
+
+// selectReviewers applies a selection strategy to the candidates.
+func (r *Resolver) selectReviewers(selection Selection, candidates []Owner) []Owner {
+	if selection.Strategy == "" || selection.Strategy == StrategyAll || selection.Count >= len(candidates) {
+		return candidates
+	}
+
+	// Order candidates by name, so the selection doesn't depend on the order of the owners
+	sorted := append([]Owner{}, candidates...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+
+	switch selection.Strategy {
+	case StrategyRandom:
+		r.rand.Shuffle(len(sorted), func(i, j int) { sorted[i], sorted[j] = sorted[j], sorted[i] })
+	case StrategyLeastBusy:
+		if r.OpenReviews != nil {
+			sort.SliceStable(sorted, func(i, j int) bool { return r.OpenReviews(sorted[i]) < r.OpenReviews(sorted[j]) })
+		}
+	}
+	return sorted[:selection.Count]
+}
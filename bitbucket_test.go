@@ -0,0 +1,142 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bitbucketFile = `# Groups
+@@@Backend @alice @bob carol@example.com
+@@@Frontend @dave @erin
+@@@Everyone @@Backend @@Frontend
+
+* @@Everyone
+/api/ random(2) @@Backend @org/api-team
+/web/ least_busy(1) @@Frontend all @frank
+!/api/generated/
+`
+
+func TestParseBitbucket(t *testing.T) {
+	groups := make(map[string][]Owner)
+	rules, err := ParseFile(strings.NewReader(bitbucketFile), WithDialect(DialectBitbucket), WithGroups(groups))
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+
+	assert.Equal(t, map[string][]Owner{
+		"Backend":  mustOwners(t, "@alice", "@bob", "carol@example.com"),
+		"Frontend": mustOwners(t, "@dave", "@erin"),
+		"Everyone": {{Value: "Backend", Type: GroupOwner}, {Value: "Frontend", Type: GroupOwner}},
+	}, groups)
+
+	backend, apiTeam := Owner{Value: "Backend", Type: GroupOwner}, Owner{Value: "org/api-team", Type: TeamOwner}
+	random2 := Selection{Strategy: StrategyRandom, Count: 2}
+	assert.Equal(t, []Owner{backend, apiTeam}, rules[1].Owners)
+	assert.Equal(t, map[Owner]Selection{backend: random2, apiTeam: random2}, rules[1].Selections)
+	assert.Equal(t, Selection{Strategy: StrategyAll}, rules[2].Selections[rules[2].Owners[1]])
+	assert.Nil(t, rules[0].Selections)
+	assert.True(t, rules[3].Negated)
+	assert.Equal(t, "!/api/generated/", rules[3].RawPattern())
+
+	// Group definitions are kept with the comments, so files are written back unchanged
+	doc, err := ParseDocument(strings.NewReader(bitbucketFile), WithDialect(DialectBitbucket))
+	require.NoError(t, err)
+	assert.Equal(t, bitbucketFile, doc.String())
+
+	// Exclusions leave matching files unowned
+	listing, err := ListOwners(rules, []string{"api/server.go", "api/generated/types.go"}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, Owners{
+		{Path: "api/server.go", Owners: rules[1].Owners},
+		{Path: "api/generated/types.go", Owners: []Owner{Unowned}},
+	}, listing)
+
+	rule, err := NewMatcher(rules).Match("api/generated/types.go")
+	require.NoError(t, err)
+	assert.Equal(t, &rules[3], rule)
+}
+
+func TestParseBitbucketErrors(t *testing.T) {
+	for file, expected := range map[string]string{
+		"* random(2)\n":                   "line 1: selection strategy random(2) isn't followed by owners",
+		"!/docs/ @alice\n":                "line 1: exclusion !/docs/ can't have owners",
+		"@@@Team @a\n@@@Team @b\n":        "line 2: group @@Team is defined more than once",
+		"@@@Team not-an-owner\n":          "line 1: invalid owner format 'not-an-owner'",
+		"* random(0) @@Team\n":            "line 1: invalid owner format 'random(0)' at position 3",
+		"* @@Team\n/a/ first(1) @alice\n": "line 2: invalid owner format 'first(1)' at position 5",
+	} {
+		_, err := ParseFile(strings.NewReader(file), WithDialect(DialectBitbucket))
+		assert.EqualError(t, err, expected, file)
+	}
+
+	// GitHub files don't have groups or strategies
+	_, err := ParseFile(strings.NewReader("* @@Team\n"))
+	assert.EqualError(t, err, "line 1: invalid owner format '@@Team' at position 3")
+	_, err = ParseFile(strings.NewReader("* random(2) @alice\n"))
+	assert.EqualError(t, err, "line 1: unexpected character '(' at position 9")
+}
+
+func TestReplaceOwnersSelection(t *testing.T) {
+	rules, err := ParseFile(strings.NewReader("* random(1) @alice @bob\n/docs/ @alice\n"), WithDialect(DialectBitbucket))
+	require.NoError(t, err)
+
+	// Owners are the same whatever their selection, and replacements keep it
+	changes := Ruleset(rules).ReplaceOwners(map[Owner][]Owner{
+		{Value: "alice", Type: UsernameOwner}: mustOwners(t, "@dave"),
+	})
+	assert.Len(t, changes, 2)
+	assert.Equal(t, "* random(1) @dave @bob", rules[0].String())
+	assert.Equal(t, "/docs/ @dave", rules[1].String())
+}
+
+func TestResolver(t *testing.T) {
+	groups := make(map[string][]Owner)
+	rules, err := ParseFile(strings.NewReader(bitbucketFile), WithDialect(DialectBitbucket), WithGroups(groups))
+	require.NoError(t, err)
+
+	resolver := NewResolver(groups, 1)
+	everyone, err := resolver.Reviewers(rules[0].Owners, rules[0].Selections)
+	require.NoError(t, err)
+	assert.Equal(t, mustOwners(t, "@alice", "@bob", "carol@example.com", "@dave", "@erin"), everyone)
+
+	// Random selections depend only on the seed
+	picked, err := resolver.Reviewers(rules[1].Owners, rules[1].Selections)
+	require.NoError(t, err)
+	assert.Len(t, picked, 2)
+	for seed := int64(0); seed < 10; seed++ {
+		a, err := NewResolver(groups, seed).Reviewers(rules[1].Owners, rules[1].Selections)
+		require.NoError(t, err)
+		b, err := NewResolver(groups, seed).Reviewers(rules[1].Owners, rules[1].Selections)
+		require.NoError(t, err)
+		assert.Equal(t, a, b)
+		assert.Subset(t, mustOwners(t, "@alice", "@bob", "carol@example.com", "@org/api-team"), a)
+	}
+
+	// The least busy owners are picked, by name among equally busy ones, followed by all owners
+	// with the all strategy
+	reviews := map[string]int{"dave": 3, "erin": 1}
+	resolver.OpenReviews = func(owner Owner) int { return reviews[owner.Value] }
+	picked, err = resolver.Reviewers(rules[2].Owners, rules[2].Selections)
+	require.NoError(t, err)
+	assert.Equal(t, mustOwners(t, "@erin", "@frank"), picked)
+
+	reviews["erin"] = 3
+	picked, err = resolver.Reviewers(rules[2].Owners, rules[2].Selections)
+	require.NoError(t, err)
+	assert.Equal(t, mustOwners(t, "@dave", "@frank"), picked)
+}
+
+func TestResolverErrors(t *testing.T) {
+	resolver := NewResolver(map[string][]Owner{
+		"A": {{Value: "B", Type: GroupOwner}},
+		"B": {{Value: "A", Type: GroupOwner}},
+	}, 0)
+
+	_, err := resolver.Reviewers([]Owner{{Value: "A", Type: GroupOwner}}, nil)
+	assert.EqualError(t, err, "group cycle: @@A -> @@B -> @@A")
+
+	_, err = resolver.Reviewers([]Owner{{Value: "C", Type: GroupOwner}}, nil)
+	assert.EqualError(t, err, "group @@C is not defined")
+}
@@ -0,0 +1,125 @@
+package codeowners
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseChromiumOwners parses a Chromium-style OWNERS file, as used by Gerrit, for LoadNestedFS:
+//
+//	# Owners of everything in this directory
+//	alice@example.com
+//	set noparent
+//	file://ipc/SECURITY_OWNERS
+//	include //build/OWNERS.build
+//	per-file *.mojom,*.proto=file://ipc/SECURITY_OWNERS
+//	per-file BUILD.gn=*
+//
+// "file:" adds the owners of another file, and "include" its per-file rules as well. Paths starting
+// with "//" are relative to the repository root, and others to the directory of the file. Per-file
+// globs match files in the directory of the file. "*" lets anyone approve, which CODEOWNERS can only
+// write as a rule without owners, so it is reported in the file's warnings.
+func ParseChromiumOwners(f io.Reader) (*OwnersFile, error) {
+	file := &OwnersFile{}
+	scanner := bufio.NewScanner(f)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 && (i == 0 || isWhitespace(rune(line[i-1]))) {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "per-file "):
+			rules, err := parsePerFile(strings.TrimPrefix(line, "per-file "), lineNo, file)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			file.Rules = append(file.Rules, rules...)
+			continue
+		case strings.HasPrefix(line, "include "):
+			p := strings.TrimSpace(strings.TrimPrefix(line, "include "))
+			file.Includes = append(file.Includes, OwnersInclude{Path: p, Line: lineNo, Rules: true})
+			continue
+		case strings.HasPrefix(line, "file:"):
+			file.Includes = append(file.Includes, OwnersInclude{Path: chromiumFilePath(line), Line: lineNo})
+			continue
+		case line == "set noparent":
+			file.NoParent = true
+		case line == "*":
+			file.Anyone = true
+			file.Warnings = append(file.Warnings, anyoneWarning(lineNo))
+		default:
+			owner, err := newOwner(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: unknown directive or owner '%s'", lineNo, line)
+			}
+			file.Owners = append(file.Owners, owner)
+		}
+
+		if file.Line == 0 {
+			file.Line = lineNo
+		}
+	}
+
+	return file, scanner.Err()
+}
+
+// parsePerFile parses the globs and directives of a per-file line, returning a rule for each glob.
+func parsePerFile(line string, lineNo int, file *OwnersFile) ([]OwnersRule, error) {
+	globs, directives, ok := strings.Cut(line, "=")
+	if !ok {
+		return nil, fmt.Errorf("per-file needs globs and owners separated by =")
+	}
+
+	rule := OwnersRule{Line: lineNo}
+	for _, directive := range strings.Split(directives, ",") {
+		directive = strings.TrimSpace(directive)
+		switch {
+		case directive == "":
+			continue
+		case directive == "set noparent":
+			rule.NoParent = true
+		case directive == "*":
+			rule.Anyone = true
+			file.Warnings = append(file.Warnings, anyoneWarning(lineNo))
+		case strings.HasPrefix(directive, "file:"):
+			rule.Includes = append(rule.Includes, chromiumFilePath(directive))
+		default:
+			owner, err := newOwner(directive)
+			if err != nil {
+				return nil, fmt.Errorf("unknown directive or owner '%s'", directive)
+			}
+			rule.Owners = append(rule.Owners, owner)
+		}
+	}
+
+	var rules []OwnersRule
+	for _, glob := range strings.Split(globs, ",") {
+		if glob = strings.TrimSpace(glob); glob == "" {
+			continue
+		}
+		r := rule
+		r.Pattern = "/" + strings.TrimPrefix(glob, "/")
+		rules = append(rules, r)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("per-file has no globs")
+	}
+	return rules, nil
+}
+
+// chromiumFilePath returns the path of a "file:" directive, where "file://path" is relative to the
+// repository root, as is "file: //path".
+func chromiumFilePath(directive string) string {
+	return strings.TrimSpace(strings.TrimPrefix(directive, "file:"))
+}
+
+func anyoneWarning(lineNo int) string {
+	return fmt.Sprintf("line %d: * lets anyone approve, which CODEOWNERS can only write as a rule without owners", lineNo)
+}
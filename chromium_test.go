@@ -0,0 +1,106 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChromiumOwners(t *testing.T) {
+	fsys := fstest.MapFS{
+		"OWNERS": {Data: []byte(`# TEAM: core@example.com
+root@example.com
+per-file BUILD.gn=*
+`)},
+		"ipc/SECURITY_OWNERS": {Data: []byte(`sec@example.com
+per-file *.cc=ipc@example.com
+`)},
+		"build/OWNERS.build": {Data: []byte(`builder@example.com
+per-file *.gni=gn@example.com
+`)},
+		"content/OWNERS": {Data: []byte(`set noparent
+alice@example.com  # lead
+file://ipc/SECURITY_OWNERS
+include //build/OWNERS.build
+per-file *.mojom,*.proto=file://ipc/SECURITY_OWNERS
+per-file DEPS=set noparent,deps@example.com
+`)},
+		"content/renderer/OWNERS": {Data: []byte(`bob@example.com
+`)},
+	}
+	rules, err := LoadNestedFS(fsys, NestedOptions{Parse: ParseChromiumOwners})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"OWNERS:* root@example.com",
+		"OWNERS:/BUILD.gn",
+		"content/OWNERS:/content/ alice@example.com sec@example.com builder@example.com",
+		"content/OWNERS:/content/*.mojom sec@example.com alice@example.com builder@example.com",
+		"content/OWNERS:/content/*.proto sec@example.com alice@example.com builder@example.com",
+		"content/OWNERS:/content/DEPS deps@example.com",
+		"content/OWNERS:/content/*.gni gn@example.com alice@example.com sec@example.com builder@example.com",
+		"content/renderer/OWNERS:/content/renderer/ bob@example.com alice@example.com sec@example.com builder@example.com",
+	}, ruleSources(rules))
+	assert.Equal(t, 4, rules[6].SourceLine)
+
+	listing, err := ListOwners(rules, []string{"content/foo.proto", "content/v1/foo.proto"}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, Owners{
+		{Path: "content/foo.proto", Owners: mustOwners(t, "sec@example.com", "alice@example.com", "builder@example.com")},
+		{Path: "content/v1/foo.proto", Owners: mustOwners(t, "alice@example.com", "sec@example.com", "builder@example.com")},
+	}, listing)
+}
+
+func TestParseChromiumOwnersWarnings(t *testing.T) {
+	var warnings []string
+	fsys := fstest.MapFS{
+		"OWNERS": {Data: []byte("# TEAM: core@example.com\nroot@example.com\nper-file BUILD.gn=*\n")},
+	}
+	_, err := LoadNestedFS(fsys, NestedOptions{
+		Parse: ParseChromiumOwners,
+		Warn:  func(warning string) { warnings = append(warnings, warning) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"OWNERS: line 3: * lets anyone approve, which CODEOWNERS can only write as a rule without owners",
+	}, warnings)
+
+	// Anyone may approve changes beneath a directory with *, unless a subdirectory sets noparent
+	fsys = fstest.MapFS{
+		"OWNERS":         {Data: []byte("*\n")},
+		"a/OWNERS":       {Data: []byte("alice@example.com\n")},
+		"b/OWNERS":       {Data: []byte("set noparent\nbob@example.com\n")},
+		"b/c/OWNERS":     {Data: []byte("per-file *.go=*\n")},
+		"d/OWNERS.extra": {Data: []byte("*\n")},
+	}
+	rules, err := LoadNestedFS(fsys, NestedOptions{Parse: ParseChromiumOwners})
+	require.NoError(t, err)
+
+	lines := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		lines = append(lines, strings.TrimSpace(rule.String()))
+	}
+	assert.Equal(t, []string{"*", "/a/", "/b/ bob@example.com", "/b/c/ bob@example.com", "/b/c/*.go"}, lines)
+}
+
+func TestParseChromiumOwnersErrors(t *testing.T) {
+	for data, expected := range map[string]string{
+		"alice@example.com\nset noparents\n": "OWNERS: line 2: unknown directive or owner 'set noparents'",
+		"per-file *.cc\n":                    "OWNERS: line 1: per-file needs globs and owners separated by =",
+		"per-file =alice@example.com\n":      "OWNERS: line 1: per-file has no globs",
+		"per-file *.cc=alice, bob\n":         "OWNERS: line 1: unknown directive or owner 'alice'",
+		"file://missing/OWNERS\n":            "OWNERS: line 1: included file //missing/OWNERS doesn't exist",
+		"include OWNERS.a\n":                 "OWNERS: include cycle: OWNERS -> OWNERS.a -> OWNERS",
+		"per-file x=file://missing/OWNERS\n": "OWNERS: line 1: included file //missing/OWNERS doesn't exist",
+	} {
+		fsys := fstest.MapFS{
+			"OWNERS":   {Data: []byte(data)},
+			"OWNERS.a": {Data: []byte("file:OWNERS\n")},
+		}
+		_, err := LoadNestedFS(fsys, NestedOptions{Parse: ParseChromiumOwners})
+		assert.EqualError(t, err, expected, data)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	codeowners "github.com/lukealbao/co"
+	"github.com/spf13/cobra"
+)
+
+// Shell completion. Completion functions run without the rules loaded by the root command, so they
+// load what they need themselves and offer nothing when that fails.
+
+// completionRules loads the CODEOWNERS file given with --file, or the one at a standard location.
+func completionRules(cmd *cobra.Command) codeowners.Ruleset {
+	var rules codeowners.Ruleset
+	var err error
+	if path := cmd.Flag("file").Value.String(); path != "" {
+		rules, err = codeowners.LoadFile(path, parseOptions()...)
+	} else {
+		rules, err = codeowners.LoadFileFromStandardLocation(parseOptions()...)
+	}
+	if err != nil {
+		return nil
+	}
+	return rules
+}
+
+// completeOwners completes the owners that appear in the CODEOWNERS file. It handles the
+// comma-separated values of slice flags such as --owner.
+func completeOwners(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	done := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 && cmd.Flags().Lookup("owner") != nil {
+		done, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+
+	seen := make(map[string]bool)
+	owners := make([]string, 0)
+	for _, rule := range completionRules(cmd) {
+		for _, owner := range rule.Owners {
+			name := owner.String()
+			if !seen[name] && strings.HasPrefix(name, toComplete) {
+				seen[name] = true
+				owners = append(owners, done+name)
+			}
+		}
+	}
+	sort.Strings(owners)
+
+	return owners, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePaths completes the paths of tracked files, relative to the working directory, one
+// directory at a time.
+func completePaths(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	root, inRepo := repositoryRoot()
+	if !inRepo {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	files, err := codeowners.LsFiles("")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	cwd, ok := relativeToRoot(root, ".")
+	if !ok {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	prefix := ""
+	if cwd != "." {
+		prefix = cwd + "/"
+	}
+
+	return completePathsFrom(files, prefix, toComplete), cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePathsFrom completes toComplete from the files beneath prefix, stopping after the next
+// directory separator so that directories are completed before the files in them.
+func completePathsFrom(files []string, prefix, toComplete string) []string {
+	seen := make(map[string]bool)
+	paths := make([]string, 0)
+	for _, file := range files {
+		if !strings.HasPrefix(file, prefix) {
+			continue
+		}
+		file = strings.TrimPrefix(file, prefix)
+		if !strings.HasPrefix(file, toComplete) {
+			continue
+		}
+
+		// Complete the next directory, or the file itself
+		if i := strings.Index(file[len(toComplete):], "/"); i >= 0 {
+			file = file[:len(toComplete)+i+1]
+		}
+		if !seen[file] {
+			seen[file] = true
+			paths = append(paths, file)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// completeRefs completes branches, tags and remote branches, for one side of "commit..commit".
+func completeRefs(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	done := ""
+	if i := strings.LastIndex(toComplete, ".."); i >= 0 {
+		done, toComplete = toComplete[:i+2], toComplete[i+2:]
+	}
+
+	out, err := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads", "refs/tags", "refs/remotes").Output()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	refs := make([]string, 0)
+	for _, ref := range append([]string{"HEAD"}, strings.Fields(string(out))...) {
+		if strings.HasPrefix(ref, toComplete) {
+			refs = append(refs, done+ref)
+		}
+	}
+
+	return refs, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePatterns completes the patterns of the rules in the CODEOWNERS file.
+func completePatterns(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	seen := make(map[string]bool)
+	patterns := make([]string, 0)
+	for _, rule := range completionRules(cmd) {
+		pattern := rule.RawPattern()
+		if !seen[pattern] && strings.HasPrefix(pattern, toComplete) {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeValues returns a completion function offering a fixed list of values.
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completePatternThenOwners completes a rule pattern for the first argument and owners for the
+// rest, as taken by add and set.
+func completePatternThenOwners(patterns func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective)) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return patterns(cmd, args, toComplete)
+		}
+		return completeOwners(cmd, args, toComplete)
+	}
+}
+
+// completeAnchoredPaths completes tracked files as patterns anchored at the repository root.
+func completeAnchoredPaths(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	files, err := codeowners.LsFiles("")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	paths := completePathsFrom(files, "", strings.TrimPrefix(toComplete, "/"))
+	for i, p := range paths {
+		paths[i] = "/" + p
+	}
+	return paths, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeDiffArgs completes refs, and paths after "--".
+func completeDiffArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// Cobra appends its own "--" when parsing flags for completion, so ArgsLenAtDash can't tell
+	// whether one was typed. The words being completed follow the __complete command instead.
+	for _, word := range os.Args[2 : len(os.Args)-1] {
+		if word == "--" {
+			return completePaths(cmd, args, toComplete)
+		}
+	}
+	if len(args) >= 2 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeRefs(cmd, args, toComplete)
+}
+
+func registerCompletions() {
+	mustRegister := func(cmd *cobra.Command, flag string, fn func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective)) {
+		if err := cmd.RegisterFlagCompletionFunc(flag, fn); err != nil {
+			panic(err)
+		}
+	}
+
+	mustRegister(root, "format", completeValues("table", "json", "ndjson", "csv", "tsv", "template="))
+	mustRegister(root, "color", completeValues("auto", "always", "never"))
+	mustRegister(whoCmd, "owner", completeOwners)
+	mustRegister(whoCmd, "owner-type", completeValues("team", "username", "email", "group"))
+	mustRegister(fmtCmd, "ref", completeRefs)
+	mustRegister(generateCmd, "input-format", completeValues("auto", "json", "csv"))
+	mustRegister(root, "dialect", completeValues("github", "bitbucket", "gitlab"))
+	mustRegister(root, "owners-format", completeValues("co", "k8s", "chromium"))
+	mustRegister(importCmd, "from", completeValues("k8s-owners", "chromium-owners"))
+	mustRegister(exportCmd, "to", completeValues("k8s-owners"))
+	mustRegister(exportCmd, "dir", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	})
+	for _, cmd := range []*cobra.Command{addCmd, setCmd} {
+		mustRegister(cmd, "after", completePaths)
+	}
+
+	whoCmd.ValidArgsFunction = completePaths
+	statsCmd.ValidArgsFunction = completePaths
+	whyCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completePaths(cmd, args, toComplete)
+	}
+	diffCmd.ValidArgsFunction = completeDiffArgs
+	addCmd.ValidArgsFunction = completePatternThenOwners(completeAnchoredPaths)
+	setCmd.ValidArgsFunction = completePatternThenOwners(completePatterns)
+	rmCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completePatterns(cmd, args, toComplete)
+	}
+	replaceOwnerCmd.ValidArgsFunction = completeOwners
+	removeOwnerCmd.ValidArgsFunction = completeOwners
+	for _, cmd := range []*cobra.Command{fmtCmd, lintCmd, suggestCmd, versionCmd} {
+		cmd.ValidArgsFunction = cobra.NoFileCompletions
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	codeowners "github.com/lukealbao/co"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the name of the project config file.
+const configFileName = ".co.yaml"
+
+// config is the project config file. Paths are relative to the repository root.
+type config struct {
+	CodeOwners string `yaml:"codeowners"`
+	// Dialect is the dialect of the CODEOWNERS file.
+	Dialect string `yaml:"dialect"`
+	// GitignorePatterns allows the full gitignore pattern syntax.
+	GitignorePatterns bool `yaml:"gitignore-patterns"`
+	// OwnersFiles are the names of nested OWNERS files to load.
+	OwnersFiles  []string `yaml:"owners-files"`
+	OwnersFormat string   `yaml:"owners-format"`
+	Roster       string   `yaml:"roster"`
+	Format       string   `yaml:"format"`
+	Color        string   `yaml:"color"`
+	Lint         struct {
+		Checks map[string]string `yaml:"checks"`
+	} `yaml:"lint"`
+	// Ignore lists patterns for files left out of ownership coverage, in CODEOWNERS syntax.
+	Ignore []string `yaml:"ignore"`
+	// Commands holds flag defaults for each command, by command and flag name.
+	Commands map[string]map[string]interface{} `yaml:"commands"`
+}
+
+// Config state, set by loadConfig.
+var (
+	// configPath is the --config flag, and then the config file loaded, if any.
+	configPath    string
+	projectConfig config
+	// ignoreRules matches the files left out of coverage.
+	ignoreRules codeowners.Ruleset
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the project config file",
+	Long: `co reads settings from a .co.yaml file, looked for next to the CODEOWNERS file given with
+--file, then at the root of the repository, in .github/ and in docs/. Use --config to give its path
+instead. Flags given on the command line take precedence over the config file.
+
+    # Paths are relative to the repository root
+    codeowners: .github/CODEOWNERS
+    dialect: github               # default --dialect
+    gitignore-patterns: false     # default --gitignore-patterns
+    owners-files: [OWNERS]        # default --owners-files
+    owners-format: chromium       # default --owners-format
+    roster: .github/teams.txt     # default --roster for co suggest
+    format: table                 # default --format
+    color: auto                   # default --color
+
+    lint:
+      checks:
+        unused-rules: error       # error, warning or off
+        github-syntax: warning    # patterns using syntax GitHub doesn't support
+
+    # Files left out of ownership coverage by co stats and co who --unowned
+    ignore:
+      - /vendor/
+      - "*.pb.go"
+
+    # Flag defaults for each command
+    commands:
+      diff:
+        renames: true
+      suggest:
+        min-confidence: 0.7`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each setting comes from",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		format, err := commandFormat(cmd)
+		exitIf(err)
+
+		settings := effectiveSettings(cmd)
+		rep := report{
+			value:  settings,
+			header: []string{"setting", "value", "source"},
+			row: func(record interface{}) []string {
+				s := record.(setting)
+				return []string{s.Name, s.Value, s.Source}
+			},
+			table: func(w io.Writer) {
+				for _, s := range settings {
+					fmt.Fprintf(w, "%-40s %-30s %s\n", s.Name, s.Value, s.Source)
+				}
+			},
+		}
+		for _, s := range settings {
+			rep.records = append(rep.records, s)
+		}
+
+		out := newOutput(cmd)
+		defer out.Close()
+		exitIf(format.write(out, rep))
+	},
+}
+
+// setting is a line of co config show.
+type setting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// findConfig returns the path of the config file to load, or "" if there is none.
+func findConfig(cmd *cobra.Command) (string, error) {
+	if cmd.Flags().Changed("config") {
+		if _, err := os.Stat(configPath); err != nil {
+			return "", err
+		}
+		return configPath, nil
+	}
+
+	root, _ := repositoryRoot()
+	dirs := []string{root, filepath.Join(root, ".github"), filepath.Join(root, "docs")}
+	if codeownersPath != "" {
+		dirs = append([]string{filepath.Dir(codeownersPath)}, dirs...)
+	}
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// loadConfig reads the config file, if any, and applies its settings to the flags of cmd that
+// weren't given on the command line.
+func loadConfig(cmd *cobra.Command) error {
+	path, err := findConfig(cmd)
+	if err != nil || path == "" {
+		configPath = ""
+		return err
+	}
+	configPath = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&projectConfig); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := applyConfig(cmd); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func applyConfig(cmd *cobra.Command) error {
+	c := projectConfig
+	root, _ := repositoryRoot()
+
+	if c.CodeOwners != "" && !cmd.Flags().Changed("file") {
+		codeownersPath = filepath.Join(root, filepath.FromSlash(c.CodeOwners))
+	}
+	if c.Dialect != "" && !cmd.Flags().Changed("dialect") {
+		dialect = c.Dialect
+	}
+	if c.GitignorePatterns && !cmd.Flags().Changed("gitignore-patterns") {
+		gitignorePatterns = true
+	}
+	if len(c.OwnersFiles) > 0 && !cmd.Flags().Changed("owners-files") {
+		ownersFileNames = c.OwnersFiles
+	}
+	if c.OwnersFormat != "" && !cmd.Flags().Changed("owners-format") {
+		ownersFormat = c.OwnersFormat
+	}
+	if c.Format != "" && !cmd.Flags().Changed("format") {
+		outputFormat = c.Format
+	}
+	if c.Color != "" && !cmd.Flags().Changed("color") {
+		colorMode = c.Color
+	}
+	if flag := cmd.Flags().Lookup("roster"); c.Roster != "" && flag != nil && !flag.Changed {
+		if err := flag.Value.Set(filepath.Join(root, filepath.FromSlash(c.Roster))); err != nil {
+			return err
+		}
+	}
+
+	for check, severity := range c.Lint.Checks {
+		if _, ok := lintChecks[check]; !ok {
+			return fmt.Errorf("unknown lint check %q", check)
+		}
+		switch severity {
+		case "error", "warning", "off":
+			lintChecks[check] = severity
+		default:
+			return fmt.Errorf("invalid severity %q for lint check %s: must be error, warning or off", severity, check)
+		}
+	}
+
+	ignoreRules = nil
+	for _, pattern := range c.Ignore {
+		rule, err := codeowners.NewRuleWith(pattern, nil, parseOptions()...)
+		if err != nil {
+			return fmt.Errorf("ignore: %w", err)
+		}
+		ignoreRules = append(ignoreRules, rule)
+	}
+
+	for name, flags := range c.Commands {
+		target, _, err := cmd.Root().Find([]string{name})
+		if err != nil || target == cmd.Root() {
+			return fmt.Errorf("unknown command %q", name)
+		}
+		for flagName, value := range flags {
+			flag := target.Flags().Lookup(flagName)
+			if flag == nil {
+				flag = target.InheritedFlags().Lookup(flagName)
+			}
+			if flag == nil {
+				return fmt.Errorf("unknown flag --%s for co %s", flagName, name)
+			}
+			if target != cmd || flag.Changed {
+				continue
+			}
+			if err := flag.Value.Set(configValue(value)); err != nil {
+				return fmt.Errorf("invalid value %v for --%s of co %s: %w", value, flagName, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// configValue converts a flag value from the config file to its command line form.
+func configValue(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		parts := make([]string, len(list))
+		for i, v := range list {
+			parts[i] = fmt.Sprint(v)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// coveredFiles returns the files that the config file doesn't leave out of coverage.
+func coveredFiles(files []string) []string {
+	if len(ignoreRules) == 0 {
+		return files
+	}
+	matcher := codeowners.NewMatcher(ignoreRules)
+	out := make([]string, 0, len(files))
+	for _, file := range files {
+		if rule, _ := matcher.Match(file); rule == nil {
+			out = append(out, file)
+		}
+	}
+	return out
+}
+
+// effectiveSettings lists the settings in effect for cmd and their sources.
+func effectiveSettings(cmd *cobra.Command) []setting {
+	fromConfig := "config " + configPath
+	source := func(flag string, inConfig bool) string {
+		switch {
+		case flag != "" && cmd.Flags().Changed(flag):
+			return "flag --" + flag
+		case inConfig:
+			return fromConfig
+		default:
+			return "default"
+		}
+	}
+
+	config := setting{"config", configPath, "flag --config"}
+	switch {
+	case configPath == "":
+		config.Value, config.Source = "(none)", "default"
+	case !cmd.Flags().Changed("config"):
+		config.Source = "discovered"
+	}
+	settings := []setting{config}
+
+	file := setting{"codeowners", codeownersPath, source("file", projectConfig.CodeOwners != "")}
+	if codeownersPath == "" {
+		file.Value, file.Source = findCodeownersFile(), "standard location"
+	}
+	settings = append(settings,
+		file,
+		setting{"dialect", dialect, source("dialect", projectConfig.Dialect != "")},
+		setting{"gitignore-patterns", strconv.FormatBool(gitignorePatterns), source("gitignore-patterns", projectConfig.GitignorePatterns)},
+		setting{"owners-files", strings.Join(ownersFileNames, ","), source("owners-files", len(projectConfig.OwnersFiles) > 0)},
+		setting{"owners-format", ownersFormat, source("owners-format", projectConfig.OwnersFormat != "")},
+		setting{"roster", projectConfig.Roster, source("", projectConfig.Roster != "")},
+		setting{"format", outputFormat, source("format", projectConfig.Format != "")},
+		setting{"color", colorMode, source("color", projectConfig.Color != "")},
+	)
+
+	for _, check := range sortedKeys(lintChecks) {
+		_, inConfig := projectConfig.Lint.Checks[check]
+		settings = append(settings, setting{"lint.checks." + check, lintChecks[check], source("", inConfig)})
+	}
+
+	settings = append(settings, setting{"ignore", strings.Join(projectConfig.Ignore, " "), source("", len(projectConfig.Ignore) > 0)})
+
+	for _, name := range sortedKeys(projectConfig.Commands) {
+		flags := projectConfig.Commands[name]
+		for _, flag := range sortedKeys(flags) {
+			settings = append(settings, setting{"commands." + name + "." + flag, configValue(flags[flag]), fromConfig})
+		}
+	}
+
+	return settings
+}
+
+// findCodeownersFile returns the CODEOWNERS file at a standard location, if any.
+func findCodeownersFile() string {
+	if path := codeowners.FindFileAtStandardLocation(); path != "" {
+		return path
+	}
+	return "(none)"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	codeowners "github.com/lukealbao/co"
+	"github.com/spf13/cobra"
+)
+
+// k8sAliasesFile is the file defining the aliases of Kubernetes OWNERS files, at the repository root.
+const k8sAliasesFile = "OWNERS_ALIASES"
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Convert another tool's ownership files to a CODEOWNERS file",
+	Long: `Print a CODEOWNERS file converted from the ownership files of another tool, found among the files
+tracked by git.
+
+With --from k8s-owners, Kubernetes-style OWNERS files, as used by Prow, are read along with the
+OWNERS_ALIASES file at the repository root:
+
+    co import --from k8s-owners > .github/CODEOWNERS
+
+Approvers own their directory and everything beneath it, in addition to the approvers of parent
+directories unless the file sets no_parent_owners. Aliases are expanded to their members, or with
+--alias-org become teams of that organization. A filter for ".*" adds owners to the whole
+directory, and other filters must match literal paths or extensions, such as "^docs/",
+"^(?:api|db)\.go$" or "\.proto$".
+
+With --from chromium-owners, Chromium-style OWNERS files, as used by Gerrit, are read instead. They
+list the owners of their directory, which inherits the owners of its parent unless the file says
+"set noparent", and "per-file" globs with owners of their own. "file:" and "include" directives add
+the owners of other files, with paths starting with "//" relative to the repository root. A "*"
+owner, which lets anyone approve, can only be written as a rule without owners, and is reported in a
+warning.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		from, err := cmd.Flags().GetString("from")
+		exitIf(err)
+		includeReviewers, err := cmd.Flags().GetBool("include-reviewers")
+		exitIf(err)
+		aliasOrg, err := cmd.Flags().GetString("alias-org")
+		exitIf(err)
+
+		if from != "k8s-owners" && from != "chromium-owners" {
+			exitIf(fmt.Errorf("unknown format %q for --from: must be k8s-owners or chromium-owners", from))
+		}
+		parse, err := ownersParser(strings.TrimSuffix(from, "-owners"), codeowners.K8sOptions{
+			AliasOrg:         aliasOrg,
+			IncludeReviewers: includeReviewers,
+		})
+		exitIf(err)
+
+		root, _ := repositoryRoot()
+		files, err := codeowners.LsFiles("")
+		exitIf(err)
+
+		rules, err := codeowners.LoadNestedFS(os.DirFS(root), codeowners.NestedOptions{
+			Parse: parse,
+			Files: files,
+			Warn:  func(warning string) { fmt.Fprintln(os.Stderr, "warning:", warning) },
+		})
+		exitIf(err)
+
+		out := newOutput(cmd)
+		defer out.Close()
+
+		source := ""
+		for _, rule := range rules {
+			if rule.SourceFile != source {
+				if source != "" {
+					fmt.Fprintln(out)
+				}
+				source = rule.SourceFile
+				fmt.Fprintf(out, "# From %s\n", source)
+			}
+			fmt.Fprintln(out, rule.String())
+		}
+
+		fmt.Fprintf(os.Stderr, "%d rules from %d OWNERS files\n", len(rules), countSources(rules))
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Convert the CODEOWNERS file to another tool's ownership files",
+	Long: `Write the ownership files of another tool giving every file tracked by git the same owners as
+the CODEOWNERS file.
+
+With --to k8s-owners, Kubernetes-style OWNERS files, as used by Prow, are written along with an
+OWNERS_ALIASES file listing the teams:
+
+    co export --to k8s-owners --dry-run
+
+Each OWNERS file approves the owners most of its directory's files share, filters add the owners
+of the other files and subdirectories, and OWNERS files are only written where owners differ from
+those a directory inherits. Teams become aliases named
+after the team, whose members are left for you to fill in, and email owners, which OWNERS files
+can't list, are left out with a warning. Existing files are overwritten.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		to, err := cmd.Flags().GetString("to")
+		exitIf(err)
+		if to != "k8s-owners" {
+			exitIf(fmt.Errorf("unknown format %q for --to: must be k8s-owners", to))
+		}
+		dir, err := cmd.Flags().GetString("dir")
+		exitIf(err)
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		exitIf(err)
+
+		if dir == "" {
+			dir, _ = repositoryRoot()
+		}
+
+		files, err := codeowners.LsFiles("")
+		exitIf(err)
+		listing, err := codeowners.ListOwnersContext(cmd.Context(), sessionRules, files, codeowners.ListOptions{Workers: workers})
+		exitIf(err)
+
+		export, err := codeowners.ExportK8sOwners(listing)
+		exitIf(err)
+		for _, warning := range export.Warnings {
+			fmt.Fprintln(os.Stderr, "warning:", warning)
+		}
+
+		// The files to write, by path relative to dir
+		contents := make(map[string][]byte)
+		for ownersDir := range export.Files {
+			data, err := export.Marshal(ownersDir)
+			exitIf(err)
+			contents[export.Path(ownersDir)] = data
+		}
+		if len(export.Aliases) > 0 {
+			data, err := export.MarshalAliases()
+			exitIf(err)
+			contents[k8sAliasesFile] = data
+		}
+
+		paths := make([]string, 0, len(contents))
+		for p := range contents {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+
+		if dryRun {
+			out := newOutput(cmd)
+			defer out.Close()
+			for i, p := range paths {
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				fmt.Fprintf(out, "# %s\n%s", p, contents[p])
+			}
+			return
+		}
+
+		for _, p := range paths {
+			target := filepath.Join(dir, filepath.FromSlash(p))
+			exitIf(os.MkdirAll(filepath.Dir(target), 0o755))
+			exitIf(os.WriteFile(target, contents[p], 0o644))
+		}
+		fmt.Fprintf(os.Stderr, "wrote %d files to %s\n", len(paths), dir)
+	},
+}
+
+// countSources returns the number of files the rules came from.
+func countSources(rules codeowners.Ruleset) int {
+	sources := make(map[string]bool)
+	for _, rule := range rules {
+		sources[rule.SourceFile] = true
+	}
+	return len(sources)
+}
@@ -5,12 +5,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"regexp"
-	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/fatih/color"
@@ -23,10 +21,42 @@ var (
 	diffRulesFrom codeowners.Ruleset
 	diffRefTo     string
 	diffRulesTo   codeowners.Ruleset
+	diffLabelFrom string
+	diffLabelTo   string
+	diffPathspecs []string
 )
 
+// loadDiffRules loads the rules for one side of a diff: from file if set ("-" meaning stdin),
+// otherwise from the CODEOWNERS file at ref.
+func loadDiffRules(cmd *cobra.Command, ref, file string) (codeowners.Ruleset, error) {
+	switch {
+	case file == "-":
+		return codeowners.ParseFile(cmd.InOrStdin(), parseOptions()...)
+	case file != "":
+		return codeowners.LoadFile(file, parseOptions()...)
+	}
+
+	if path := cmd.Flag("file").Value.String(); path != "" {
+		return codeowners.LoadFileAtRef(ref, path, parseOptions()...)
+	}
+	return codeowners.LoadFileFromStandardLocationAtRef(ref, parseOptions()...)
+}
+
+// diffLabel names one side of a diff in its header.
+func diffLabel(ref, file string) string {
+	switch {
+	case file == "-":
+		return "stdin"
+	case file != "":
+		return file
+	case ref == "":
+		return "git-index"
+	}
+	return ref
+}
+
 var diffCmd = &cobra.Command{
-	Use:   "diff [commit | commit..commit | commit commit]",
+	Use:   "diff [commit | commit..commit | commit commit] [-- <pathspec>...]",
 	Short: "Print a unified diff of file ownership",
 	Long: `Diff calculates the ownership of all files at the given valid git refs, and prints a
 unified diff of their text outputs.
@@ -34,11 +64,68 @@ unified diff of their text outputs.
 Commit refs behave mostly like git diff, with subtle differences when comparing uncommitted changes.
 The codeowners file will be read from disk, so any uncommitted changes will be considered. All other
 files will be listed from the git index. That is, renamed, deleted, or added files will be considered
-only after staging those changes.
+only after staging those changes, unless --untracked is given to include new files that are neither
+staged nor ignored.
+
+Paths after "--" restrict the comparison to matching files, as with git diff. Each pathspec is a
+file, a directory, or a glob in which * and ? also match "/", relative to the current directory:
+
+    co diff main -- services/payments '*.proto'
+
+Each changed file is printed with its old owners on a "-" line and its new owners on a "+" line.
+Use --format=json (or --json) for a structured list of changes, each with the kind of change
+(added, removed, renamed or modified), the old and new owners, and the owners gained and lost:
+
+    [
+      {
+        "kind": "modified",
+        "path": "api/server.go",
+        "oldOwners": [{"name": "@org/a", "type": "team", "org": "org"}],
+        "newOwners": [{"name": "@org/b", "type": "team", "org": "org"}],
+        "gained": [{"name": "@org/b", "type": "team", "org": "org"}],
+        "lost": [{"name": "@org/a", "type": "team", "org": "org"}]
+      }
+    ]
+
+Use --stat to summarize the diff per owner instead, counting the files each owner gained and lost,
+followed by the number of files transferred from one owner to another:
+
+    Owner                                                Gained     Lost
+    @org/b                                                 +312       -0
+    @org/a                                                   +0     -312
+    ----------------------------------------------
+    @org/a -> @org/b                                   312
+    312 files changed ownership
+
+The ndjson, csv, tsv and template formats write one record per changed file, or one per owner
+with --stat; transfers between owners are only included in the table and json formats.
+
+Use --old and --new to compare CODEOWNERS files directly, instead of the CODEOWNERS file at each
+ref. Either may be "-" to read from stdin. Without refs, both files are applied to the files in the
+git index, so a proposed or generated CODEOWNERS file can be evaluated before it is committed:
+
+    co diff --new proposed/CODEOWNERS
+    generate-codeowners | co diff --old .github/CODEOWNERS --new -
+
+The exit status is 1 if ownership changed.
 
 Flags:
-  -r, --renames Files that have been renamed will be printed as their latest name for both versions.`,
+  -r, --renames Files that have been renamed will be printed as their latest name for both versions.
+      --stat    Summarize changes per owner.
+      --old     CODEOWNERS file to compare from.
+      --new     CODEOWNERS file to compare to.
+      --untracked Include untracked files that are not ignored when comparing to the working tree.`,
 	Args: func(cmd *cobra.Command, args []string) error {
+		diffPathspecs = nil
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			args, diffPathspecs = args[:dash], args[dash:]
+		}
+
+		if len(args) > 2 {
+			fmt.Fprintln(cmd.ErrOrStderr(), fmt.Errorf("too many refs: %s", args))
+			return cmd.Help()
+		}
+
 		refs := make([]string, 2, 2)
 
 		for i, a := range args {
@@ -58,7 +145,11 @@ Flags:
 		}
 
 		validRef := func(ref string) bool {
-			_, err := exec.Command("git", "rev-parse", ref).Output()
+			repo, err := codeowners.OpenRepository(".")
+			if err != nil {
+				return false
+			}
+			_, err = repo.ResolveRef(ref)
 			return err == nil
 		}
 
@@ -72,178 +163,194 @@ Flags:
 			return cmd.Help()
 		}
 
-		// If we have only one ref, we may be looking to compare dirty file state with HEAD.
-		if first == "" {
+		oldFile, err := cmd.Flags().GetString("old")
+		exitIf(err)
+		newFile, err := cmd.Flags().GetString("new")
+		exitIf(err)
+
+		if oldFile == "-" && newFile == "-" {
+			return fmt.Errorf("only one of --old and --new can be read from stdin")
+		}
+
+		switch {
+		case first == "" && (oldFile != "" || newFile != ""):
+			// Comparing CODEOWNERS files directly: use the files in the index for both.
+			first, second = "", ""
+		case first == "":
+			// If we have only one ref, we may be looking to compare dirty file state with HEAD.
 			first, second = "HEAD", ""
 		}
 
 		diffRefFrom, diffRefTo = first, second
+		diffLabelFrom, diffLabelTo = diffLabel(first, oldFile), diffLabel(second, newFile)
 
-		codeOwnersPath := cmd.Flag("file").Value.String()
-		if codeOwnersPath == "" {
-			var err error
-			diffRulesFrom, err = codeowners.LoadFileFromStandardLocationAtRef(first)
-			diffRulesTo, err = codeowners.LoadFileFromStandardLocationAtRef(second)
-			if err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
-				os.Exit(1)
-			}
-		} else {
-			var err error
-			diffRulesFrom, err = codeowners.LoadFileAtRef(first, codeOwnersPath)
-			diffRulesTo, err = codeowners.LoadFileAtRef(second, codeOwnersPath)
-			if err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
-				os.Exit(1)
-			}
-		}
-
-		return nil
-	},
-	Run: func(cmd *cobra.Command, files []string) {
-		// TODO: user may want different refs. AND: may want to specify paths.
-		trackedFilesFrom, err := codeowners.LsFiles(diffRefFrom)
+		diffRulesFrom, err = loadDiffRules(cmd, first, oldFile)
 		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s\n", err)
 			os.Exit(1)
 		}
 
-		refs, err := newFollower(".", diffRefFrom, diffRefTo)
+		diffRulesTo, err = loadDiffRules(cmd, second, newFile)
 		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s\n", err)
 			os.Exit(1)
 		}
 
-		trackedFilesTo, err := codeowners.LsFiles(diffRefTo)
-		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
-			os.Exit(1)
-		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, files []string) {
+		trackedFilesFrom, err := codeowners.LsFiles(diffRefFrom)
+		exitIf(err)
 
-		owners1, err := codeowners.ListOwners(diffRulesFrom, trackedFilesFrom, nil, false)
-		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
-			os.Exit(1)
-		}
+		trackedFilesTo, err := codeowners.LsFiles(diffRefTo)
+		exitIf(err)
 
-		owners2, err := codeowners.ListOwners(diffRulesTo, trackedFilesTo, nil, false)
-		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
-			os.Exit(1)
-		}
+		untracked, err := cmd.Flags().GetBool("untracked")
+		exitIf(err)
 
-		Tmp, err := ioutil.TempDir("", "co-diff-")
-		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
-			os.Exit(1)
-		}
+		if untracked {
+			if diffRefTo != "" {
+				exitIf(fmt.Errorf("--untracked can only be used when comparing against the working tree"))
+			}
 
-		rev1, _ := exec.Command("git", "rev-parse", diffRefFrom).Output()
-		if string(rev1) == "\n" {
-			rev1 = []byte("git-index")
-		} else {
-			rev1 = []byte(strings.Trim(fmt.Sprintf("%s", rev1), " \n"))
+			files, err := codeowners.LsUntrackedFiles()
+			exitIf(err)
+			trackedFilesTo = append(trackedFilesTo, files...)
 		}
 
-		tmp1, err := os.Create(filepath.Join(Tmp, fmt.Sprintf("owners@%s.list", rev1)))
-		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
-			os.Exit(1)
+		if len(diffPathspecs) > 0 {
+			root, _ := repositoryRoot()
+			trackedFilesFrom = filterPathspecs(root, trackedFilesFrom, diffPathspecs)
+			trackedFilesTo = filterPathspecs(root, trackedFilesTo, diffPathspecs)
 		}
 
-		// Todo, this is a hack to sort.
-		for _, result := range owners1 {
-			if follow, err := cmd.Flags().GetBool("renames"); err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
-				os.Exit(1)
-			} else if follow {
-				result.Path = refs.latestName(result.Path)
-			}
-		}
+		follow, err := cmd.Flags().GetBool("renames")
+		exitIf(err)
 
-		var oowners1 codeowners.Owners = owners1
-		sort.Sort(oowners1)
+		var renames map[string]string
+		if follow {
+			refs, err := newFollower(".", diffRefFrom, diffRefTo)
+			exitIf(err)
 
-		for _, result := range oowners1 {
-			fmt.Fprintf(tmp1, "%-70s %s\n", result.Path, result.Owners)
+			renames = make(map[string]string)
+			for _, file := range trackedFilesFrom {
+				if latest := refs.latestName(file); latest != file {
+					renames[file] = latest
+				}
+			}
 		}
 
-		rev2, _ := exec.Command("git", "rev-parse", diffRefTo).Output()
-		if string(rev2) == "\n" {
-			rev2 = []byte("git-index")
-		} else {
-			rev2 = []byte(strings.Trim(fmt.Sprintf("%s", rev2), " \n"))
+		changes, err := codeowners.DiffOwnership(cmd.Context(), diffRulesFrom, trackedFilesFrom, diffRulesTo, trackedFilesTo, codeowners.DiffOptions{
+			Renames: renames,
+			Workers: workers,
+		})
+		exitIf(err)
+
+		format, err := commandFormat(cmd)
+		exitIf(err)
+
+		summarize, err := cmd.Flags().GetBool("stat")
+		exitIf(err)
+
+		// Renamed files that keep their owners are listed, but don't count as changes of ownership
+		stat := codeowners.SummarizeOwnershipDiff(changes)
+		rep := report{
+			value:  changes,
+			header: []string{"kind", "path", "oldPath", "oldOwners", "newOwners", "gained", "lost"},
+			row: func(record interface{}) []string {
+				change := record.(codeowners.OwnershipChange)
+				return []string{
+					string(change.Kind), change.Path, change.OldPath,
+					ownerList(change.OldOwners), ownerList(change.NewOwners),
+					ownerList(change.Gained), ownerList(change.Lost),
+				}
+			},
+			table: func(w io.Writer) { printOwnershipDiff(w, changes) },
 		}
-		tmp2, err := os.Create(filepath.Join(Tmp, fmt.Sprintf("owners@%s.list", rev2)))
-		if err != nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s", err)
-			os.Exit(1)
+		for _, change := range changes {
+			rep.records = append(rep.records, change)
 		}
-		for _, result := range owners2 {
-			fmt.Fprintf(tmp2, "%-70s %s\n", result.Path, result.Owners)
+
+		if summarize {
+			rep = report{
+				value:  stat,
+				header: []string{"owner", "type", "gained", "lost"},
+				row: func(record interface{}) []string {
+					owner := record.(codeowners.OwnerDiffStat)
+					return []string{owner.Owner.String(), owner.Owner.Type, strconv.Itoa(owner.Gained), strconv.Itoa(owner.Lost)}
+				},
+				table: func(w io.Writer) { printOwnershipDiffStat(w, stat) },
+			}
+			for _, owner := range stat.Owners {
+				rep.records = append(rep.records, owner)
+			}
 		}
 
-		diff := exec.Command("git", "diff", "-u", "-w", "--no-index", tmp1.Name(), tmp2.Name())
-		// exit code 1 on diff is not a real error in this use case.
-		unifiedDiff, _ := diff.CombinedOutput()
+		out := newOutput(cmd)
+		defer out.Close()
 
-		lines := strings.Split(string(unifiedDiff), "\n")
-		var wasDiff bool
+		exitIf(format.write(out, rep))
 
-		var output io.Writer
+		if stat.ChangedFiles > 0 {
+			exit(1)
+		}
+	},
+}
 
-		var pager string
-		if pager = os.Getenv("PAGER"); pager == "" {
-			pager = "less"
+// printOwnershipDiff writes changes in a unified-diff-like format, with the old ownership of each
+// file on a "-" line and its new ownership on a "+" line. Renamed files that keep their owners are
+// left out.
+func printOwnershipDiff(w io.Writer, changes []codeowners.OwnershipChange) {
+	list := func(owners []codeowners.Owner) string {
+		if len(owners) == 0 {
+			return "[(unowned)]"
 		}
+		return fmt.Sprint(owners)
+	}
 
-		p := exec.Command(pager, "-R", "-F", "-X")
-		p.Stdout = os.Stdout
-		p.Stderr = os.Stderr
+	var wasDiff bool
+	for _, change := range changes {
+		if change.Kind == codeowners.FileRenamed && len(change.Gained) == 0 && len(change.Lost) == 0 {
+			continue
+		}
 
-		stdin, err := p.StdinPipe()
-		if err != nil {
-			fmt.Fprintln(cmd.ErrOrStderr(), err)
-			os.Exit(1)
+		if !wasDiff {
+			fmt.Fprintf(w, "--- owners@%s\n+++ owners@%s\n", diffLabelFrom, diffLabelTo)
+			wasDiff = true
 		}
-		output = stdin
 
-		if err := p.Start(); err != nil {
-			fmt.Fprintln(cmd.ErrOrStderr(), err)
-			os.Exit(1)
+		if change.Kind != codeowners.FileAdded {
+			fmt.Fprintln(w, color.RedString("-%-70s %s", change.Path, list(change.OldOwners)))
+		}
+		if change.Kind != codeowners.FileRemoved {
+			fmt.Fprintln(w, color.GreenString("+%-70s %s", change.Path, list(change.NewOwners)))
 		}
+	}
+}
 
-		defer func() {
-			if err := p.Wait(); err != nil {
-				fmt.Fprintln(cmd.ErrOrStderr(), err)
-				os.Exit(1)
-			}
+// printOwnershipDiffStat writes the files gained and lost per owner, followed by the transfers
+// between owners. It reports whether any ownership changed.
+func printOwnershipDiffStat(w io.Writer, stat codeowners.DiffStat) bool {
+	if stat.ChangedFiles == 0 {
+		return false
+	}
 
-			if wasDiff {
-				os.Exit(1)
-			}
-		}()
-
-		for i, line := range lines {
-			switch {
-			case strings.HasPrefix(line, "+"):
-				fmt.Fprintln(output, color.GreenString(line))
-				wasDiff = true
-				break
-			case strings.HasPrefix(line, "-"):
-				fmt.Fprintln(output, color.RedString(line))
-				wasDiff = true
-				break
-			default:
-				// Don't print empty final line.
-				if i != len(lines)-1 || line != "" {
-					fmt.Fprintln(output, line)
-				}
-			}
+	fmt.Fprintf(w, "%-50s %8s %8s\n", "Owner", "Gained", "Lost")
+	for _, owner := range stat.Owners {
+		gained := fmt.Sprintf("%8s", fmt.Sprintf("+%d", owner.Gained))
+		lost := fmt.Sprintf("%8s", fmt.Sprintf("-%d", owner.Lost))
+		fmt.Fprintf(w, "%-50s %s %s\n", owner.Owner, color.GreenString(gained), color.RedString(lost))
+	}
+
+	if len(stat.Transfers) > 0 {
+		fmt.Fprintln(w, "----------------------------------------------")
+		for _, transfer := range stat.Transfers {
+			fmt.Fprintf(w, "%-50s %d\n", transfer.From.String()+" -> "+transfer.To.String(), transfer.Count)
 		}
+	}
 
-		stdin.Close()
-	},
+	fmt.Fprintf(w, "%d files changed ownership\n", stat.ChangedFiles)
+	return true
 }
 
 // follower maps older names to newer names.
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	codeowners "github.com/lukealbao/co"
+	"github.com/spf13/cobra"
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add pattern owner...",
+	Short: "Add a rule to the CODEOWNERS file",
+	Long: `Add a rule to the CODEOWNERS file, preserving comments.
+
+The rule is added at the end of the file, where it takes precedence over every other rule. Use
+--after to add it after the rule that currently owns a path instead, keeping related rules
+together:
+
+    co add /services/payments/ @org/payments --after services/payments
+
+Owners may be omitted to leave matching files unowned. It is an error to add a pattern that
+already has a rule; use "co set" to change its owners.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		doc, rule := loadDocumentAndRule(args)
+		if doc.Rules.Index(rule.RawPattern()) >= 0 {
+			exitIf(fmt.Errorf("a rule for %s already exists, use co set to change its owners", rule.RawPattern()))
+		}
+
+		insertRule(cmd, doc, rule)
+		exitIf(writeDocument(doc))
+	},
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set pattern owner...",
+	Short: "Set the owners of a rule in the CODEOWNERS file",
+	Long: `Set the owners of a rule in the CODEOWNERS file, preserving comments.
+
+If the pattern has no rule, one is added as with "co add". Otherwise the owners of its last rule,
+the one that takes effect, are replaced. Owners may be omitted to leave matching files unowned.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		doc, rule := loadDocumentAndRule(args)
+		if doc.Rules.Index(rule.RawPattern()) >= 0 {
+			exitIf(doc.Rules.Update(rule.RawPattern(), rule.Owners...))
+		} else {
+			insertRule(cmd, doc, rule)
+		}
+
+		exitIf(writeDocument(doc))
+	},
+}
+
+var rmCmd = &cobra.Command{
+	Use:   "rm pattern",
+	Short: "Remove a rule from the CODEOWNERS file",
+	Long: `Remove the last rule with the pattern from the CODEOWNERS file. Comments above the rule
+are kept.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		doc := loadDocument()
+
+		_, err := doc.Delete(args[0])
+		exitIf(err)
+		exitIf(writeDocument(doc))
+	},
+}
+
+// loadDocument parses the CODEOWNERS file for editing.
+func loadDocument() *codeowners.Document {
+	file, err := os.Open(codeownersPath)
+	exitIf(err)
+	defer file.Close()
+
+	doc, err := codeowners.ParseDocument(file, parseOptions()...)
+	exitIf(err)
+	return doc
+}
+
+// loadDocumentAndRule parses the CODEOWNERS file, and the rule given by a pattern and owners.
+func loadDocumentAndRule(args []string) (*codeowners.Document, codeowners.Rule) {
+	rule, err := codeowners.NewRuleWith(args[0], parseOwners(args[1:]), parseOptions()...)
+	exitIf(err)
+
+	return loadDocument(), rule
+}
+
+// insertRule adds the rule after the rule owning the --after path, or at the end of the file.
+func insertRule(cmd *cobra.Command, doc *codeowners.Document, rule codeowners.Rule) {
+	after, err := cmd.Flags().GetString("after")
+	exitIf(err)
+
+	if after == "" {
+		doc.Rules = append(doc.Rules, rule)
+		return
+	}
+
+	root, _ := repositoryRoot()
+	rel, ok := relativeToRoot(root, after)
+	if !ok {
+		exitIf(fmt.Errorf("%s is outside the repository", after))
+	}
+	_, err = doc.Rules.InsertAfter(rel, rule)
+	exitIf(err)
+}
+
+// writeDocument replaces the CODEOWNERS file with the edited document.
+func writeDocument(doc *codeowners.Document) error {
+	file, err := os.OpenFile(codeownersPath, os.O_TRUNC|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := doc.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	codeowners "github.com/lukealbao/co"
+)
+
+// Flags controlling which files directory arguments expand to.
+var (
+	includeUntracked bool
+	includeAllFiles  bool
+)
+
+// listFiles returns the files to check for the given path arguments, relative to the repository
+// root. Directories expand to the files tracked in the git index beneath them, plus untracked files
+// that aren't ignored with --untracked, or every file on disk with --all-files. With no arguments,
+// all files at HEAD are listed, or the whole working tree when either flag is set.
+func listFiles(args []string) ([]string, error) {
+	root, inRepo := repositoryRoot()
+
+	if len(args) == 0 {
+		if !includeUntracked && !includeAllFiles {
+			return codeowners.LsFiles("HEAD")
+		}
+		args = []string{root}
+	}
+
+	if !inRepo || includeAllFiles {
+		return expandAllFiles(args), nil
+	}
+
+	files, err := codeowners.LsFiles("")
+	if err != nil {
+		return nil, err
+	}
+	if includeUntracked {
+		untracked, err := codeowners.LsUntrackedFiles()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, untracked...)
+	}
+
+	out := make([]string, 0)
+	for _, arg := range args {
+		rel, ok := relativeToRoot(root, arg)
+		if !ok {
+			// Outside the repository, so there's no index to consult
+			out = append(out, expandAllFiles([]string{arg})...)
+			continue
+		}
+
+		if info, err := os.Stat(arg); err != nil || !info.IsDir() {
+			out = append(out, rel)
+			continue
+		}
+
+		for _, file := range files {
+			if rel == "." || strings.HasPrefix(file, rel+"/") {
+				out = append(out, file)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// expandAllFiles expands directories to every file beneath them on disk, skipping .git directories.
+// Paths within the repository are made relative to its root.
+func expandAllFiles(paths []string) []string {
+	root, inRepo := repositoryRoot()
+	out := make([]string, 0)
+
+	for _, p := range paths {
+		var files []string
+
+		info, err := os.Stat(p)
+		if err != nil || !info.IsDir() {
+			files = []string{p}
+		} else {
+			found, err := codeowners.ListFilesFS(os.DirFS(p))
+			exitIf(err)
+
+			for _, file := range found {
+				files = append(files, filepath.Join(p, filepath.FromSlash(file)))
+			}
+		}
+
+		for _, file := range files {
+			if rel, ok := relativeToRoot(root, file); inRepo && ok {
+				file = rel
+			}
+			out = append(out, file)
+		}
+	}
+
+	return out
+}
+
+// repositoryRoot returns the root of the git repository containing the working directory, or the
+// working directory itself when not in a repository.
+func repositoryRoot() (string, bool) {
+	if repo, err := codeowners.OpenRepository("."); err == nil {
+		return repo.Root(), true
+	}
+	return ".", false
+}
+
+// relativeToRoot converts a path relative to the working directory into a slash-separated path
+// relative to root. It reports false if the path is outside root.
+func relativeToRoot(root, path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(absRoot, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// filterPathspecs returns the files, relative to root, that match any of the pathspecs, which are
+// relative to the working directory.
+func filterPathspecs(root string, files, pathspecs []string) []string {
+	matchers := make([]func(string) bool, 0, len(pathspecs))
+	for _, spec := range pathspecs {
+		matchers = append(matchers, pathspecMatcher(root, spec))
+	}
+
+	out := make([]string, 0)
+	for _, file := range files {
+		for _, match := range matchers {
+			if match(file) {
+				out = append(out, file)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// pathspecMatcher returns a function matching paths relative to root against a git-style pathspec
+// relative to the working directory. Invalid globs only match themselves.
+func pathspecMatcher(root, spec string) func(string) bool {
+	rel, ok := relativeToRoot(root, spec)
+	if !ok {
+		return func(string) bool { return false }
+	}
+
+	match, err := codeowners.PathspecMatcher(rel)
+	if err != nil {
+		return func(path string) bool { return path == rel }
+	}
+	return match
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/lukealbao/co"
 	"github.com/spf13/cobra"
@@ -13,20 +16,50 @@ var fmtCmd = &cobra.Command{
 	Short: "Normalize CODEOWNERS format",
 	Long: `Format CODEOWNERS file in place.
 
-The file will be lexicographically sorted. Use --trim to remove redundant rules.`,
-	Run: func(cmd *cobra.Command, files []string) {
-		tree := codeowners.NewFileTree(sessionRules)
+The file will be lexicographically sorted. Use --trim to remove redundant rules instead: rules whose
+removal changes the owners of no file tracked by git, because they match no files, every file they
+match is owned by a later rule, or the files they own get the same owners from earlier rules. The
+other rules and comments are kept in place, so the file isn't sorted.
 
+Use --ref to trim against the files tracked at another commit, and --dry-run to print the rules
+that would be removed and why, without writing the file.`,
+	Run: func(cmd *cobra.Command, files []string) {
 		trim, err := cmd.Flags().GetBool("trim")
-		if err != nil {
-			fmt.Fprintln(cmd.ErrOrStderr(), err)
-			os.Exit(1)
+		exitIf(err)
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		exitIf(err)
+		ref, err := cmd.Flags().GetString("ref")
+		exitIf(err)
+
+		if (dryRun || ref != "") && !trim {
+			exitIf(fmt.Errorf("--dry-run and --ref can only be used with --trim"))
 		}
 
 		if trim {
-			codeowners.ConsolidateTree(tree)
+			tracked, err := codeowners.LsFiles(ref)
+			exitIf(err)
+			redundant, err := codeowners.RedundantRules(sessionRules, tracked)
+			exitIf(err)
+
+			if dryRun {
+				printRedundantRules(cmd, redundant)
+				return
+			}
+
+			doc := loadDocument()
+			for _, r := range redundant {
+				_, err := doc.DeleteLine(r.Line)
+				exitIf(err)
+			}
+			exitIf(writeDocument(doc))
+			if len(redundant) > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "removed %d redundant rules\n", len(redundant))
+			}
+			return
 		}
 
+		tree := codeowners.NewFileTree(sessionRules)
+
 		file, err := os.OpenFile(codeownersPath, os.O_TRUNC|os.O_WRONLY, os.ModePerm)
 		if err != nil {
 			fmt.Fprintln(cmd.ErrOrStderr(), err)
@@ -44,3 +77,64 @@ The file will be lexicographically sorted. Use --trim to remove redundant rules.
 		}
 	},
 }
+
+func printRedundantRules(cmd *cobra.Command, redundant []codeowners.RedundantRule) {
+	format, err := commandFormat(cmd)
+	exitIf(err)
+
+	rep := report{
+		value:  redundant,
+		header: []string{"line", "pattern", "owners", "reason", "by"},
+		row: func(record interface{}) []string {
+			r := record.(codeowners.RedundantRule)
+			return []string{strconv.Itoa(r.Line), r.Pattern, ownerList(r.Owners), string(r.Reason), lineList(r.By)}
+		},
+		table: func(w io.Writer) {
+			for _, r := range redundant {
+				fmt.Fprintf(w, "%4d %-50s %s\n", r.Line, strings.TrimSpace(r.Pattern+" "+ownerList(r.Owners)), redundancyDescription(r))
+			}
+		},
+	}
+	for _, r := range redundant {
+		rep.records = append(rep.records, r)
+	}
+
+	out := newOutput(cmd)
+	defer out.Close()
+	exitIf(format.write(out, rep))
+}
+
+// redundancyDescription explains why a rule is redundant, for the table output.
+func redundancyDescription(r codeowners.RedundantRule) string {
+	switch {
+	case r.Reason == codeowners.Unmatched:
+		return "matches no files"
+	case r.Reason == codeowners.Shadowed:
+		return "every file is owned by " + linesDescription(r.By)
+	case len(r.By) == 0:
+		return "its files are unowned without it"
+	default:
+		return "its files get the same owners from " + linesDescription(r.By)
+	}
+}
+
+// lineList returns the line numbers separated by spaces, for csv and tsv output.
+func lineList(lines []int) string {
+	parts := make([]string, len(lines))
+	for i, line := range lines {
+		parts[i] = strconv.Itoa(line)
+	}
+	return strings.Join(parts, " ")
+}
+
+// linesDescription returns "line 3" or "lines 3, 7".
+func linesDescription(lines []int) string {
+	parts := make([]string, len(lines))
+	for i, line := range lines {
+		parts[i] = strconv.Itoa(line)
+	}
+	if len(parts) == 1 {
+		return "line " + parts[0]
+	}
+	return "lines " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+
+	codeowners "github.com/lukealbao/co"
+	"github.com/spf13/cobra"
+)
+
+// outputFormat is the --format flag shared by all commands.
+var outputFormat string
+
+const formatHelp = "output format: table, json, ndjson, csv, tsv or template='{{.Path}} {{join .Owners \",\"}}'"
+
+// formatter writes a command's results in the format chosen with --format.
+type formatter struct {
+	kind     string
+	template *template.Template
+}
+
+// templateFuncs are the functions available to --format=template.
+var templateFuncs = template.FuncMap{
+	// join joins the elements of any list with sep
+	"join": func(list interface{}, sep string) string {
+		v := reflect.ValueOf(list)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return fmt.Sprint(list)
+		}
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(parts, sep)
+	},
+}
+
+// parseFormat validates a --format value.
+func parseFormat(format string) (*formatter, error) {
+	switch format {
+	case "", "table":
+		return &formatter{kind: "table"}, nil
+	case "json", "ndjson", "csv", "tsv":
+		return &formatter{kind: format}, nil
+	}
+
+	if strings.HasPrefix(format, "template=") {
+		tmpl, err := template.New("format").Funcs(templateFuncs).Parse(strings.TrimPrefix(format, "template="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --format template: %w", err)
+		}
+		return &formatter{kind: "template", template: tmpl}, nil
+	}
+
+	return nil, fmt.Errorf("invalid --format %q: must be table, json, ndjson, csv, tsv or template=...", format)
+}
+
+// commandFormat returns the formatter for cmd. The older --json flag, where a command has one, is
+// the same as --format=json.
+func commandFormat(cmd *cobra.Command) (*formatter, error) {
+	if flag := cmd.Flags().Lookup("json"); flag != nil && flag.Value.String() == "true" {
+		return &formatter{kind: "json"}, nil
+	}
+	return parseFormat(outputFormat)
+}
+
+// report is a command's results, in the forms each output format needs.
+type report struct {
+	// value is written as a single document with --format=json.
+	value interface{}
+	// records are written one per line with ndjson, csv, tsv and template formats.
+	records []interface{}
+	// header names the csv and tsv columns, and row returns the columns of a record.
+	header []string
+	row    func(record interface{}) []string
+	// table writes the human-readable output.
+	table func(w io.Writer)
+}
+
+func (f *formatter) write(w io.Writer, rep report) error {
+	switch f.kind {
+	case "json":
+		bytes, err := json.MarshalIndent(rep.value, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", bytes)
+		return err
+
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, record := range rep.records {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+		if f.kind == "tsv" {
+			cw.Comma = '\t'
+		}
+		if err := cw.Write(rep.header); err != nil {
+			return err
+		}
+		for _, record := range rep.records {
+			if err := cw.Write(rep.row(record)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "template":
+		for _, record := range rep.records {
+			if err := f.template.Execute(w, record); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	rep.table(w)
+	return nil
+}
+
+// ownerList joins owners with spaces, as they are written in CODEOWNERS, for csv and tsv columns.
+func ownerList(owners []codeowners.Owner) string {
+	names := make([]string, len(owners))
+	for i, owner := range owners {
+		names[i] = owner.String()
+	}
+	return strings.Join(names, " ")
+}
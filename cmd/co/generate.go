@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	codeowners "github.com/lukealbao/co"
+	"github.com/spf13/cobra"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate [mapping-file|-]",
+	Short: "Generate a CODEOWNERS file from the owners of each file",
+	Long: `Generate the smallest CODEOWNERS file giving each file exactly the owners listed for it.
+
+The mapping is read from a file, or from stdin if it is - or missing, in either of the formats
+written by "co who":
+
+    co who --json > owners.json     # edit owners.json, then
+    co generate owners.json > .github/CODEOWNERS
+
+JSON is an array of {path, owners} objects, where owners are names or {name} objects. CSV has a
+header with path and owners columns, and owners separated by spaces. Files without owners, or owned
+by (unowned), are left unowned. The format is detected from the file extension or the content, or
+given with --input-format.
+
+Directories get a single rule when most files in them share owners, and extensions whose files all
+share owners get a glob such as *.proto. The generated rules are checked against the mapping before
+they are printed, and files not in the mapping may end up with any owners.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inputFormat, err := cmd.Flags().GetString("input-format")
+		exitIf(err)
+
+		name := "-"
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		var input io.Reader = os.Stdin
+		if name != "-" {
+			file, err := os.Open(name)
+			exitIf(err)
+			defer file.Close()
+			input = file
+		}
+
+		files, err := readOwnershipMapping(input, name, inputFormat)
+		exitIf(err)
+
+		rules, err := codeowners.GenerateRules(files)
+		exitIf(err)
+
+		out := newOutput(cmd)
+		defer out.Close()
+
+		for _, rule := range rules {
+			fmt.Fprintln(out, rule.String())
+		}
+
+		fmt.Fprintf(os.Stderr, "%d rules for %d files\n", len(rules), len(files))
+	},
+}
+
+// readOwnershipMapping reads the files and owners to generate rules for, as JSON or CSV. With the
+// auto format, it is chosen from the extension of name, then from the first character of the input.
+func readOwnershipMapping(r io.Reader, name, format string) (codeowners.Owners, error) {
+	if format == "auto" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".json":
+			format = "json"
+		case ".csv":
+			format = "csv"
+		default:
+			br := bufio.NewReader(r)
+			start, _ := br.Peek(512)
+			format = "csv"
+			if strings.HasPrefix(string(bytes.TrimSpace(start)), "[") {
+				format = "json"
+			}
+			r = br
+		}
+	}
+
+	switch format {
+	case "json":
+		files := make(codeowners.Owners, 0)
+		if err := json.NewDecoder(r).Decode(&files); err != nil {
+			return nil, fmt.Errorf("reading JSON mapping: %w", err)
+		}
+		return files, nil
+	case "csv":
+		return readOwnershipCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown input format %q: must be auto, json or csv", format)
+	}
+}
+
+// readOwnershipCSV reads the path and owners columns of a CSV file with a header.
+func readOwnershipCSV(r io.Reader) (codeowners.Owners, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV mapping: %w", err)
+	}
+	pathCol, ownersCol := -1, -1
+	for i, col := range header {
+		switch strings.TrimSpace(col) {
+		case "path":
+			pathCol = i
+		case "owners":
+			ownersCol = i
+		}
+	}
+	if pathCol < 0 || ownersCol < 0 {
+		return nil, fmt.Errorf("reading CSV mapping: header must have path and owners columns")
+	}
+
+	files := make(codeowners.Owners, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV mapping: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) <= pathCol || len(record) <= ownersCol {
+			return nil, fmt.Errorf("line %d: missing path or owners", line)
+		}
+
+		file := &codeowners.FileOwners{Path: record[pathCol], Owners: []codeowners.Owner{}}
+		for _, name := range strings.Fields(record[ownersCol]) {
+			if name == codeowners.Unowned.Value {
+				continue
+			}
+			owner, err := codeowners.ParseDialectOwner(name, codeowners.Dialect(dialect))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			file.Owners = append(file.Owners, owner)
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
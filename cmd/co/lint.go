@@ -2,17 +2,42 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"io"
+	"strconv"
 
 	"github.com/fatih/color"
 	"github.com/lukealbao/co"
 	"github.com/spf13/cobra"
 )
 
+// lintChecks maps each lint check to its severity: error, warning or off. Severities can be set in
+// the config file.
+var lintChecks = map[string]string{
+	"unused-rules":  "error",
+	"github-syntax": "warning",
+}
+
+// unusedRule is a rule reported by lint for not matching any file.
+type unusedRule struct {
+	// File is the nested OWNERS file of the rule, if it isn't from the CODEOWNERS file.
+	File   string             `json:"file,omitempty"`
+	Line   int                `json:"line"`
+	Rule   string             `json:"rule"`
+	Owners []codeowners.Owner `json:"owners"`
+}
+
 var lintCmd = &cobra.Command{
 	Use:   "lint",
 	Short: "Validate codeowners file",
-	Long:  `Check for unused rules.`,
+	Long: `Check for unused rules, and for patterns using syntax GitHub doesn't support, such as the
+character classes allowed by --gitignore-patterns, which GitHub reads differently. Only GitHub files
+are checked for syntax.
+
+By default unused rules fail with an error, and unsupported syntax only gives a warning. The
+severity of each check can be set to error, warning or off in the config file; see "co config".
+
+Rules from nested OWNERS files, loaded with --owners-files, are checked as well, but --fix only
+removes rules from the CODEOWNERS file.`,
 	Run: func(cmd *cobra.Command, _ []string) {
 		files, err := codeowners.LsFiles("")
 		exitIf(err)
@@ -26,10 +51,40 @@ var lintCmd = &cobra.Command{
 			return false
 		}
 
+		// inCodeowners reports whether a rule is from the CODEOWNERS file rather than a nested
+		// OWNERS file
+		root, _ := repositoryRoot()
+		codeownersFile, _ := relativeToRoot(root, codeownersPath)
+		inCodeowners := func(rule codeowners.Rule) bool {
+			return codeownersPath != "" && (rule.SourceFile == "" || rule.SourceFile == codeownersFile)
+		}
+
+		// Patterns GitHub reads differently are reported on stderr, so the report of unused rules
+		// stays the same
+		failed := false
+		if severity := lintChecks["github-syntax"]; severity != "off" && dialect == string(codeowners.DialectGitHub) {
+			label := color.HiRedString("Error")
+			if severity == "warning" {
+				label = color.HiYellowString("Warning")
+			}
+			for _, rule := range sessionRules {
+				if syntax := rule.UnsupportedSyntax(); syntax != "" && inCodeowners(rule) {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s line %d: %s uses %s, which GitHub doesn't support\n", label, rule.SourceLine, rule.RawPattern(), syntax)
+					failed = failed || severity == "error"
+				}
+			}
+		}
+		done := func() {
+			if failed {
+				exit(1)
+			}
+		}
+
 		var errors codeowners.Ruleset
 
+		severity := lintChecks["unused-rules"]
 		for _, rule := range sessionRules {
-			if !matchAny(rule, files) {
+			if severity != "off" && !matchAny(rule, files) {
 				errors = append(errors, rule)
 			}
 		}
@@ -38,43 +93,74 @@ var lintCmd = &cobra.Command{
 			exitIf(err)
 		} else if !fix {
 			if len(errors) > 0 {
-				fmt.Println(color.HiRedString("Error"), "Unused Rules:")
+				format, err := commandFormat(cmd)
+				exitIf(err)
+
+				unused := make([]unusedRule, 0, len(errors))
+				rep := report{
+					header: []string{"line", "rule", "owners"},
+					row: func(record interface{}) []string {
+						rule := record.(unusedRule)
+						return []string{strconv.Itoa(rule.Line), rule.Rule, ownerList(rule.Owners)}
+					},
+					table: func(w io.Writer) {
+						if severity == "warning" {
+							fmt.Fprintln(w, color.HiYellowString("Warning"), "Unused Rules:")
+						} else {
+							fmt.Fprintln(w, color.HiRedString("Error"), "Unused Rules:")
+						}
+						for _, rule := range errors {
+							if inCodeowners(rule) {
+								fmt.Fprintf(w, "%4d %-70s %s\n", rule.SourceLine, rule.RawPattern(), rule.Owners)
+							} else {
+								fmt.Fprintf(w, "%4d %-70s %s (%s)\n", rule.SourceLine, rule.RawPattern(), rule.Owners, rule.SourceFile)
+							}
+						}
+					},
+				}
 				for _, rule := range errors {
-					fmt.Fprintf(os.Stdout, "%4d %-70s %s\n", rule.SourceLine, rule.RawPattern(), rule.Owners)
+					u := unusedRule{Line: rule.SourceLine, Rule: rule.RawPattern(), Owners: rule.Owners}
+					if !inCodeowners(rule) {
+						u.File = rule.SourceFile
+					}
+					unused = append(unused, u)
+					rep.records = append(rep.records, u)
 				}
-				os.Exit(1)
+				rep.value = unused
+
+				out := newOutput(cmd)
+				exitIf(format.write(out, rep))
+				if severity == "warning" {
+					out.Close()
+					done()
+					return
+				}
+				exit(1)
 			} else {
+				done()
 				return
 			}
 		}
 
-		// Will attempt to fix.
-		if len(errors) == 0 {
-			return
-		}
-
-		for _, unusedRule := range errors {
-			for i, rule := range sessionRules {
-				if rule.SourceLine == unusedRule.SourceLine {
-					sessionRules = append(sessionRules[:i], sessionRules[i+1:]...)
-				}
+		// Will attempt to fix. Only rules from the CODEOWNERS file are removed, keeping the comments,
+		// section headers and group definitions above them.
+		var unused []int
+		for _, rule := range errors {
+			if inCodeowners(rule) {
+				unused = append(unused, rule.SourceLine)
 			}
 		}
-
-		file, err := os.OpenFile(codeownersPath, os.O_TRUNC|os.O_WRONLY, os.ModePerm)
-		if err != nil {
-			fmt.Fprintln(cmd.ErrOrStderr(), err)
-			os.Exit(1)
-		}
-
-		if err := file.Truncate(0); err != nil {
-			fmt.Fprintln(cmd.ErrOrStderr(), err)
-			os.Exit(1)
+		if len(unused) == 0 {
+			done()
+			return
 		}
 
-		for _, rule := range sessionRules {
-			_, err := fmt.Fprintln(file, rule.String())
+		doc := loadDocument()
+		for _, line := range unused {
+			_, err := doc.DeleteLine(line)
 			exitIf(err)
 		}
+		exitIf(writeDocument(doc))
+		done()
 	},
 }
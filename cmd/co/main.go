@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
 
 	codeowners "github.com/lukealbao/co"
 	"github.com/spf13/cobra"
@@ -10,68 +12,193 @@ import (
 var root = &cobra.Command{
 	Use: "co",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+
 		path := cmd.Flag("file").Value.String()
 		var err error
 
-		whoami := cmd.Name()
-		if whoami == "help" || whoami == "version" {
+		if err := configureColor(); err != nil {
+			return err
+		}
+		if _, err := parseFormat(outputFormat); err != nil {
+			return err
+		}
+		if _, err := codeowners.ParseDialect(dialect); err != nil {
+			return err
+		}
+
+		if !loadsRules(cmd) {
 			return nil
 		}
 
+		nested := len(ownersFileNames) > 0 && !editsCodeowners(cmd)
+		opts := append(parseOptions(), codeowners.WithGroups(sessionGroups))
 		if path == "" {
-			sessionRules, err = codeowners.LoadFileFromStandardLocationAtRef("")
 			codeownersPath = codeowners.FindFileAtStandardLocation()
+			// Nested OWNERS files don't need a CODEOWNERS file as well
+			if codeownersPath != "" || !nested {
+				sessionRules, err = codeowners.LoadFileFromStandardLocationAtRef("", opts...)
+			}
 		} else {
-			sessionRules, err = codeowners.LoadFileAtRef("", path)
+			sessionRules, err = codeowners.LoadFileAtRef("", path, opts...)
 		}
 
-		return err
+		if err != nil || !nested {
+			return err
+		}
+		return loadNestedRules()
 	},
 }
 
+// editsCodeowners reports whether the command rewrites the CODEOWNERS file from the session rules,
+// which must then only hold the rules of that file.
+func editsCodeowners(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case "fmt", "add", "set", "rm", "replace-owner", "remove-owner":
+		return true
+	}
+	return false
+}
+
+// loadsRules reports whether the command needs the rules loaded before it runs. diff loads its own
+// rules for each side of the comparison, completion functions load them when needed, and config
+// commands only report settings.
+func loadsRules(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case "help", "version", "diff", "generate", "import", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return false
+	}
+	// The completion command and its subcommands, one for each shell, and config
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "completion" || c.Name() == "config" {
+			return false
+		}
+	}
+	return true
+}
+
 // Globals
 var (
 	codeownersPath string
-	ownerFilters   []string
-	showUnowned    bool
-	sessionRules   codeowners.Ruleset
+	// dialect is the dialect of the CODEOWNERS file, such as bitbucket
+	dialect string
+	// gitignorePatterns allows the full gitignore pattern syntax, which GitHub doesn't support
+	gitignorePatterns bool
+	// ownersFileNames are the names of nested OWNERS files to load, if any
+	ownersFileNames []string
+	ownerFilters    []string
+	ownerTypes      []string
+	showUnowned     bool
+	workers         int
+	sessionRules    codeowners.Ruleset
+	// sessionGroups are the groups defined in a Bitbucket CODEOWNERS file
+	sessionGroups = make(map[string][]codeowners.Owner)
 	// Ldflags passed in by goreleaser's defaults:
 	version string
 	commit  string
 	date    string
 )
 
+// parseOptions returns the options for parsing the CODEOWNERS file.
+func parseOptions() []codeowners.ParseOption {
+	opts := []codeowners.ParseOption{codeowners.WithDialect(codeowners.Dialect(dialect))}
+	if gitignorePatterns {
+		opts = append(opts, codeowners.WithGitignorePatterns())
+	}
+	return opts
+}
+
 func init() {
 	root.PersistentFlags().StringVarP(&codeownersPath, "file", "f", "", "CODEOWNERS file path")
+	root.PersistentFlags().StringVar(&dialect, "dialect", string(codeowners.DialectGitHub), "dialect of the CODEOWNERS file: github, bitbucket or gitlab")
+	root.PersistentFlags().BoolVar(&gitignorePatterns, "gitignore-patterns", false, "allow the full gitignore pattern syntax, such as [a-z] character classes, which GitHub doesn't support")
+	root.PersistentFlags().StringSliceVar(&ownersFileNames, "owners-files", nil, "also load nested per-directory files with these names, such as OWNERS, which take precedence over CODEOWNERS")
+	root.PersistentFlags().StringVar(&ownersFormat, "owners-format", "co", "format of the nested OWNERS files: co, k8s or chromium")
+	root.PersistentFlags().StringVar(&configPath, "config", "", "config file path (default: .co.yaml next to the CODEOWNERS file or at the repository root)")
+	root.PersistentFlags().StringVar(&colorMode, "color", "auto", "colorize output: auto, always or never (auto respects NO_COLOR)")
+	root.PersistentFlags().StringVar(&outputFormat, "format", "table", formatHelp)
+	root.PersistentFlags().BoolVar(&noPager, "no-pager", false, "do not pipe output into a pager ($CO_PAGER, $PAGER or less)")
+	root.PersistentFlags().IntVar(&workers, "workers", 0, "number of files to check concurrently (default: number of CPUs)")
 	whoCmd.Flags().StringSliceVarP(&ownerFilters, "owner", "o", nil, "filter results by owner")
+	whoCmd.Flags().StringSliceVar(&ownerTypes, "owner-type", nil, "filter results by owner type: team, username, email or group")
 	whoCmd.Flags().BoolVarP(&showUnowned, "unowned", "u", false, "only show unowned files (can be combined with -o)")
-	whoCmd.Flags().BoolP("json", "j", false, "format output as json. output is Array<{path: string; owners: Array<string>}>.")
+	whoCmd.Flags().Bool("reviewers", false, "list the reviewers selected from the owners by the selection strategies of a Bitbucket file, such as random(2)")
+	whoCmd.Flags().Int64("seed", 0, "seed for the random selection of reviewers with --reviewers")
+	whoCmd.Flags().BoolP("json", "j", false, "same as --format=json. output is Array<{path: string; owners: Array<{name: string; type: string; org?: string}>}>.")
+	for _, cmd := range []*cobra.Command{whoCmd, statsCmd} {
+		cmd.Flags().BoolVar(&includeUntracked, "untracked", false, "include untracked files that are not ignored when expanding directories")
+		cmd.Flags().BoolVar(&includeAllFiles, "all-files", false, "include every file on disk when expanding directories, even if ignored")
+	}
 	root.AddCommand(whoCmd)
 
-	whyCmd.Flags().BoolP("json", "j", false, "format output as json. output is {path: string; line: string; rule: string; owners: Array<string>}.")
+	whyCmd.Flags().BoolP("json", "j", false, "same as --format=json. output is {path: string; file?: string; line: number; rule: string; owners: Array<{name: string; type: string; org?: string}>}.")
+	whyCmd.Flags().Bool("trace", false, "list every rule matching the file and the file it came from, ending with the one that takes effect")
 
 	root.AddCommand(whyCmd)
 
-	statsCmd.Flags().BoolP("json", "j", false, "format output as json")
+	statsCmd.Flags().BoolP("json", "j", false, "same as --format=json")
 	root.AddCommand(statsCmd)
 
 	diffCmd.Flags().BoolP("renames", "r", false, "follow file renames")
+	diffCmd.Flags().BoolP("json", "j", false, "same as --format=json. output is Array<{kind: string; path: string; oldPath?: string; oldOwners, newOwners, gained, lost: Array<{name: string; type: string; org?: string}>}>.")
+	diffCmd.Flags().Bool("stat", false, "summarize files gained and lost per owner, and transfers between owners")
+	diffCmd.Flags().String("old", "", "CODEOWNERS file to compare from, instead of the one at the first ref (- for stdin)")
+	diffCmd.Flags().String("new", "", "CODEOWNERS file to compare to, instead of the one at the second ref (- for stdin)")
+	diffCmd.Flags().Bool("untracked", false, "include untracked files that are not ignored when comparing against the working tree")
 	root.AddCommand(diffCmd)
 
-	fmtCmd.Flags().BoolP("trim", "t", false, "rollup rules into matching parent globs, if any exist")
+	fmtCmd.Flags().BoolP("trim", "t", false, "remove rules that change the owners of no tracked file")
+	fmtCmd.Flags().Bool("dry-run", false, "print the rules --trim would remove and why, without writing the file")
+	fmtCmd.Flags().String("ref", "", "trim against the files tracked at this ref instead of the index")
 	root.AddCommand(fmtCmd)
 
 	lintCmd.Flags().Bool("fix", false, "edit CODEOWNERS file to remove unused rules")
 	root.AddCommand(lintCmd)
 
+	for _, cmd := range []*cobra.Command{addCmd, setCmd} {
+		cmd.Flags().String("after", "", "add new rules after the rule that currently owns this path, instead of at the end")
+	}
+	root.AddCommand(addCmd, setCmd, rmCmd)
+
+	replaceOwnerCmd.Flags().String("mapping", "", "file of owners and their replacements, one owner per line")
+	for _, cmd := range []*cobra.Command{replaceOwnerCmd, removeOwnerCmd} {
+		cmd.Flags().Bool("dry-run", false, "print the changes and their impact without writing the file")
+	}
+	root.AddCommand(replaceOwnerCmd, removeOwnerCmd)
+
+	suggestCmd.Flags().String("roster", "", "file mapping owners to the emails of their members")
+	suggestCmd.Flags().Float64("min-confidence", 0.5, "share of commits, from 0 to 1, an owner needs to be suggested")
+	suggestCmd.Flags().String("since", "", "only consider commits more recent than this date, as with git log --since")
+	root.AddCommand(suggestCmd)
+
+	generateCmd.Flags().String("input-format", "auto", "format of the mapping: auto, json or csv")
+	root.AddCommand(generateCmd)
+
+	importCmd.Flags().String("from", "", "format of the files to import: k8s-owners or chromium-owners")
+	importCmd.Flags().Bool("include-reviewers", false, "make reviewers owners as well as approvers")
+	importCmd.Flags().String("alias-org", "", "turn aliases into teams of this organization instead of expanding them")
+	exportCmd.Flags().String("to", "", "format of the files to export: k8s-owners")
+	exportCmd.Flags().String("dir", "", "directory to write the files to (default: the repository root)")
+	exportCmd.Flags().Bool("dry-run", false, "print the files instead of writing them")
+	exitIf(importCmd.MarkFlagRequired("from"))
+	exitIf(exportCmd.MarkFlagRequired("to"))
+	root.AddCommand(importCmd, exportCmd)
+
+	configCmd.AddCommand(configShowCmd)
+	root.AddCommand(configCmd)
+
 	root.AddCommand(versionCmd)
 
-	// TODO: add completion
-	root.CompletionOptions.DisableDefaultCmd = true
+	registerCompletions()
 }
 
 func main() {
-	if err := root.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := root.ExecuteContext(ctx); err != nil {
 		os.Exit(1)
 	}
 }
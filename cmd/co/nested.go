@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	codeowners "github.com/lukealbao/co"
+)
+
+// ownersFormat is the format of nested OWNERS files: co, k8s or chromium.
+var ownersFormat string
+
+// ownersParser returns the parser of OWNERS files in the given format. The aliases of Kubernetes
+// OWNERS files are read from OWNERS_ALIASES at the repository root, if there is one.
+func ownersParser(format string, k8s codeowners.K8sOptions) (codeowners.OwnersParser, error) {
+	switch format {
+	case "co":
+		return codeowners.ParseOwnersFile, nil
+	case "chromium":
+		return codeowners.ParseChromiumOwners, nil
+	case "k8s":
+		root, _ := repositoryRoot()
+		f, err := os.Open(filepath.Join(root, k8sAliasesFile))
+		if errors.Is(err, os.ErrNotExist) {
+			return codeowners.K8sOwnersParser(k8s), nil
+		} else if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if k8s.Aliases, err = codeowners.ParseK8sOwnersAliases(f); err != nil {
+			return nil, fmt.Errorf("%s: %w", k8sAliasesFile, err)
+		}
+		return codeowners.K8sOwnersParser(k8s), nil
+	}
+	return nil, fmt.Errorf("unknown OWNERS format %q: must be co, k8s or chromium", format)
+}
+
+// loadNestedRules adds the rules of the nested OWNERS files tracked by git to the session rules,
+// after those of the CODEOWNERS file so that they take precedence. Each rule records the file it
+// came from.
+func loadNestedRules() error {
+	root, _ := repositoryRoot()
+	files, err := codeowners.LsFiles("")
+	if err != nil {
+		return err
+	}
+
+	parse, err := ownersParser(ownersFormat, codeowners.K8sOptions{})
+	if err != nil {
+		return err
+	}
+
+	nested, err := codeowners.LoadNestedFS(os.DirFS(root), codeowners.NestedOptions{
+		Names: ownersFileNames,
+		Parse: parse,
+		Files: files,
+	})
+	if err != nil {
+		return err
+	}
+
+	if rel, ok := relativeToRoot(root, codeownersPath); ok && codeownersPath != "" {
+		for i := range sessionRules {
+			sessionRules[i].SourceFile = rel
+		}
+	}
+	sessionRules = append(sessionRules, nested...)
+	return nil
+}
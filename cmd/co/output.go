@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+// Output flags shared by all commands.
+var (
+	colorMode string
+	noPager   bool
+)
+
+// activeOutput is the output currently open, if any, so that exiting early can flush the pager.
+var activeOutput *output
+
+// configureColor enables or disables colored output according to --color and NO_COLOR.
+func configureColor() error {
+	switch colorMode {
+	case "always":
+		color.NoColor = false
+	case "never":
+		color.NoColor = true
+	case "auto", "":
+		_, noColor := os.LookupEnv("NO_COLOR")
+		color.NoColor = noColor || !isTerminal(os.Stdout)
+	default:
+		return fmt.Errorf("invalid --color %q: must be auto, always or never", colorMode)
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// output is where a command writes its results: a pager when stdout is a terminal, or stdout.
+type output struct {
+	io.Writer
+	pager *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// newOutput opens the output for cmd. The pager is taken from $CO_PAGER, then $PAGER, defaulting to
+// less; it is skipped with --no-pager, when stdout is not a terminal, when the pager is set to an
+// empty string or "cat", or when it can't be started. Callers must Close the output when done.
+func newOutput(cmd *cobra.Command) *output {
+	out := &output{Writer: cmd.OutOrStdout()}
+	activeOutput = out
+
+	if noPager || out.Writer != os.Stdout || !isTerminal(os.Stdout) {
+		return out
+	}
+
+	pager, ok := os.LookupEnv("CO_PAGER")
+	if !ok {
+		if pager, ok = os.LookupEnv("PAGER"); !ok {
+			pager = "less"
+		}
+	}
+
+	args := strings.Fields(pager)
+	if len(args) == 0 || args[0] == "cat" {
+		return out
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return out
+	}
+
+	p := exec.Command(args[0], args[1:]...)
+	p.Stdout = os.Stdout
+	p.Stderr = os.Stderr
+	p.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		// Quit if the output fits on one screen, pass colors through, and don't clear the screen
+		p.Env = append(p.Env, "LESS=FRX")
+	}
+
+	stdin, err := p.StdinPipe()
+	if err != nil {
+		return out
+	}
+	if err := p.Start(); err != nil {
+		return out
+	}
+
+	out.Writer, out.pager, out.stdin = stdin, p, stdin
+	return out
+}
+
+// Close waits for the pager, if any, to exit.
+func (o *output) Close() error {
+	if activeOutput == o {
+		activeOutput = nil
+	}
+	if o.pager == nil {
+		return nil
+	}
+
+	o.stdin.Close()
+	err := o.pager.Wait()
+	o.pager = nil
+	return err
+}
+
+// exit closes the active output, so the pager shows everything written, then exits with code.
+func exit(code int) {
+	closeActiveOutput()
+	os.Exit(code)
+}
+
+func closeActiveOutput() {
+	if activeOutput != nil {
+		activeOutput.Close()
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+	codeowners "github.com/lukealbao/co"
+	"github.com/spf13/cobra"
+)
+
+var replaceOwnerCmd = &cobra.Command{
+	Use:   "replace-owner [old new...]",
+	Short: "Replace an owner in every rule of the CODEOWNERS file",
+	Long: `Replace an owner in every rule of the CODEOWNERS file, preserving comments.
+
+The old owner is replaced by the new owners wherever it appears, and duplicate owners are removed:
+
+    co replace-owner @org/old-team @org/new-team
+    co replace-owner @org/payments @org/billing @org/checkout
+
+For a larger reorganization, use --mapping to read replacements from a file instead. Each line
+holds an owner followed by the owners replacing it; an owner on its own is removed:
+
+    # Payments was split in two
+    @org/payments @org/billing @org/checkout
+    @departed-user
+
+The rules that changed are printed, along with any rules left without owners and the number of
+files each owner gains and loses, before the file is written. Use --dry-run to only print them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		mappingPath, err := cmd.Flags().GetString("mapping")
+		exitIf(err)
+
+		var mapping map[codeowners.Owner][]codeowners.Owner
+		switch {
+		case mappingPath != "" && len(args) > 0:
+			exitIf(fmt.Errorf("owners can't be given with --mapping"))
+		case mappingPath != "":
+			file, err := os.Open(mappingPath)
+			exitIf(err)
+			mapping, err = codeowners.ParseOwnerMapping(file, parseOptions()...)
+			file.Close()
+			exitIf(err)
+		case len(args) < 2:
+			exitIf(fmt.Errorf("replace-owner needs an owner and its replacements, or --mapping"))
+		default:
+			owners := parseOwners(args)
+			mapping = map[codeowners.Owner][]codeowners.Owner{owners[0]: owners[1:]}
+		}
+
+		replaceOwners(cmd, mapping)
+	},
+}
+
+var removeOwnerCmd = &cobra.Command{
+	Use:   "remove-owner owner...",
+	Short: "Remove owners from every rule of the CODEOWNERS file",
+	Long: `Remove owners from every rule of the CODEOWNERS file, preserving comments.
+
+As with "co replace-owner", the rules that changed are printed, along with any rules left without
+owners and the ownership impact, before the file is written. Use --dry-run to only print them.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mapping := make(map[codeowners.Owner][]codeowners.Owner)
+		for _, owner := range parseOwners(args) {
+			mapping[owner] = nil
+		}
+
+		replaceOwners(cmd, mapping)
+	},
+}
+
+// replaceReport is the result of replace-owner and remove-owner.
+type replaceReport struct {
+	Rules  []codeowners.RuleChange `json:"rules"`
+	Impact codeowners.DiffStat     `json:"impact"`
+}
+
+// replaceOwners applies the mapping to the CODEOWNERS file, reports the changes and their impact on
+// the files in the git index, and writes the file unless --dry-run is set.
+func replaceOwners(cmd *cobra.Command, mapping map[codeowners.Owner][]codeowners.Owner) {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	exitIf(err)
+
+	format, err := commandFormat(cmd)
+	exitIf(err)
+
+	doc := loadDocument()
+	before := append(codeowners.Ruleset(nil), doc.Rules...)
+	changes := doc.Rules.ReplaceOwners(mapping)
+
+	files, err := codeowners.LsFiles("")
+	exitIf(err)
+
+	diff, err := codeowners.DiffOwnership(cmd.Context(), before, files, doc.Rules, files, codeowners.DiffOptions{Workers: workers})
+	exitIf(err)
+
+	result := replaceReport{Rules: changes, Impact: codeowners.SummarizeOwnershipDiff(diff)}
+	rep := report{
+		value:  result,
+		header: []string{"line", "pattern", "oldOwners", "newOwners"},
+		row: func(record interface{}) []string {
+			change := record.(codeowners.RuleChange)
+			return []string{strconv.Itoa(change.Line), change.Pattern, ownerList(change.OldOwners), ownerList(change.NewOwners)}
+		},
+		table: func(w io.Writer) { printReplaceReport(w, result) },
+	}
+	for _, change := range changes {
+		rep.records = append(rep.records, change)
+	}
+
+	out := newOutput(cmd)
+	exitIf(format.write(out, rep))
+	out.Close()
+
+	if dryRun || len(changes) == 0 {
+		return
+	}
+	exitIf(writeDocument(doc))
+}
+
+func printReplaceReport(w io.Writer, result replaceReport) {
+	if len(result.Rules) == 0 {
+		fmt.Fprintln(w, "No rules changed")
+		return
+	}
+
+	fmt.Fprintln(w, "Rules changed:")
+	var ownerless []codeowners.RuleChange
+	for _, change := range result.Rules {
+		fmt.Fprintf(w, "%4d %-50s %s -> %s\n", change.Line, change.Pattern, change.OldOwners, change.NewOwners)
+		if change.Ownerless() {
+			ownerless = append(ownerless, change)
+		}
+	}
+
+	if len(ownerless) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, color.YellowString("Warning"), "Rules left without owners:")
+		for _, change := range ownerless {
+			fmt.Fprintf(w, "%4d %s\n", change.Line, change.Pattern)
+		}
+	}
+
+	fmt.Fprintln(w)
+	if !printOwnershipDiffStat(w, result.Impact) {
+		fmt.Fprintln(w, "No files changed ownership")
+	}
+}
+
+// parseOwners parses owners given as arguments, in the dialect of the CODEOWNERS file.
+func parseOwners(args []string) []codeowners.Owner {
+	owners := make([]codeowners.Owner, 0, len(args))
+	for _, arg := range args {
+		owner, err := codeowners.ParseDialectOwner(arg, codeowners.Dialect(dialect))
+		exitIf(err)
+		owners = append(owners, owner)
+	}
+	return owners
+}
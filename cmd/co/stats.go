@@ -1,8 +1,9 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
 
 	codeowners "github.com/lukealbao/co"
 	"github.com/spf13/cobra"
@@ -27,50 +28,58 @@ Default format displays a table:
 Unowned files are displayed as belonging to the dummy "(unowned)" group.
 Ownership percentages may add up to more than 100%, as there can be more than one owner per file.
 
-If filepaths are provided, only files matching the provided paths are considered.
+The ndjson, csv, tsv and template formats write one record per owner, with its file count and
+percentage ({{.Owner}}, {{.Count}} and {{.Percentage}} in templates); use json for the totals.
+
+If filepaths are provided, only files matching the provided paths are considered. Directories
+expand to tracked files, as with "co who". Files ignored in the config file are left out; see
+"co config".
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		var err error
-		var filesToCheck []string
-
-		if len(args) > 0 {
-			filesToCheck = expandAllFiles(args[:])
-		} else {
-			filesToCheck, err = codeowners.LsFiles("HEAD")
-			exitIf(err)
-		}
+		filesToCheck, err := listFiles(args)
+		exitIf(err)
+		filesToCheck = coveredFiles(filesToCheck)
 
-		formatJson, err := cmd.Flags().GetBool("json")
+		format, err := commandFormat(cmd)
 		exitIf(err)
 
-		files, err := codeowners.ListOwners(sessionRules, filesToCheck, ownerFilters, showUnowned)
+		files, err := codeowners.ListOwnersContext(cmd.Context(), sessionRules, filesToCheck, listOptions())
 		exitIf(err)
 
 		stats := codeowners.CalculateOwnershipStats(files)
-		if formatJson {
-			bytes, err := json.MarshalIndent(stats, "", "  ")
-			exitIf(err)
-
-			fmt.Fprintf(cmd.OutOrStdout(), "%s\n", bytes)
-		} else {
-			displayOwnershipStats(stats)
+		rep := report{
+			value:  stats,
+			header: []string{"owner", "type", "fileCount", "percentage"},
+			row: func(record interface{}) []string {
+				owner := record.(codeowners.FilesPerOwner)
+				return []string{owner.Owner.String(), owner.Owner.Type, strconv.Itoa(owner.Count), strconv.FormatFloat(owner.Percentage, 'f', 2, 64)}
+			},
+			table: func(w io.Writer) { displayOwnershipStats(w, stats) },
+		}
+		for _, owner := range stats.FilesPerOwner {
+			rep.records = append(rep.records, owner)
 		}
+
+		out := newOutput(cmd)
+		defer out.Close()
+
+		exitIf(format.write(out, rep))
 	},
 }
 
-func displayOwnershipStats(stats codeowners.OwnerStats) {
+func displayOwnershipStats(w io.Writer, stats codeowners.OwnerStats) {
 	fileCount := float64(stats.TotalFiles)
 	ownedCount := float64(stats.OwnedFiles)
 	unownedCount := float64(stats.UnownedFiles)
 	filesPerOwner := stats.FilesPerOwner
 	totalOwners := stats.OwnerCount
 
-	fmt.Printf("%-30s %.0f (%.2f%%)\n", "Total files", fileCount, (fileCount/fileCount)*100)
-	fmt.Printf("%-30s %.0f (%.2f%%)\n", "Owned files", ownedCount, (ownedCount/fileCount)*100)
-	fmt.Printf("%-30s %.0f (%.2f%%)\n", "Unowned files", unownedCount, (unownedCount/fileCount)*100)
-	fmt.Printf("%-30s %d\n", "Owner count", totalOwners)
-	fmt.Println("----------------------------------------------")
+	fmt.Fprintf(w, "%-30s %.0f (%.2f%%)\n", "Total files", fileCount, (fileCount/fileCount)*100)
+	fmt.Fprintf(w, "%-30s %.0f (%.2f%%)\n", "Owned files", ownedCount, (ownedCount/fileCount)*100)
+	fmt.Fprintf(w, "%-30s %.0f (%.2f%%)\n", "Unowned files", unownedCount, (unownedCount/fileCount)*100)
+	fmt.Fprintf(w, "%-30s %d\n", "Owner count", totalOwners)
+	fmt.Fprintln(w, "----------------------------------------------")
 	for _, kv := range filesPerOwner {
-		fmt.Printf("%-50s %d (%.2f%%)\n", kv.Owner, kv.Count, kv.Percentage)
+		fmt.Fprintf(w, "%-50s %d (%.2f%%)\n", kv.Owner, kv.Count, kv.Percentage)
 	}
 }
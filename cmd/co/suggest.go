@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	codeowners "github.com/lukealbao/co"
+	"github.com/spf13/cobra"
+)
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest",
+	Short: "Suggest owners for unowned files from git history",
+	Long: `Suggest owners for unowned files based on who changed them, according to git log.
+
+Commit authors are mapped to owners with a roster file given with --roster. Each line holds an
+owner followed by the emails of its members; authors missing from the roster are ignored. Without
+a roster, authors are suggested by email:
+
+    @org/payments alice@example.com bob@example.com
+    @org/search carol@example.com
+
+A directory gets a single rule when every file in it is unowned and one owner made at least
+--min-confidence of its commits. Otherwise its subdirectories and files are considered separately.
+New rules are added at the end of the CODEOWNERS file and never cover owned files, so existing
+ownership is unchanged.
+
+The default output is a patch to the CODEOWNERS file, with the confidence of each rule as a
+comment, that can be reviewed and applied with git apply:
+
+    co suggest --roster teams.txt > suggestions.patch
+    git apply suggestions.patch
+
+Use --format for the list of suggestions instead, with the commit counts and confidence of each.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		format, err := commandFormat(cmd)
+		exitIf(err)
+
+		opts := codeowners.SuggestOptions{}
+		opts.MinConfidence, err = cmd.Flags().GetFloat64("min-confidence")
+		exitIf(err)
+
+		if rosterPath, err := cmd.Flags().GetString("roster"); err != nil {
+			exitIf(err)
+		} else if rosterPath != "" {
+			file, err := os.Open(rosterPath)
+			exitIf(err)
+			opts.Roster, err = codeowners.ParseRoster(file)
+			file.Close()
+			exitIf(err)
+		}
+
+		since, err := cmd.Flags().GetString("since")
+		exitIf(err)
+
+		root, _ := repositoryRoot()
+		files, err := codeowners.LsFiles("")
+		exitIf(err)
+
+		listing, err := codeowners.ListOwnersContext(cmd.Context(), sessionRules, files, codeowners.ListOptions{Workers: workers})
+		exitIf(err)
+
+		history, err := gitHistory(root, since)
+		exitIf(err)
+
+		suggestions, unsuggested := codeowners.SuggestOwners(listing, history, opts)
+
+		rep := report{
+			value:  suggestions,
+			header: []string{"pattern", "owner", "fileCount", "commitCount", "confidence"},
+			row: func(record interface{}) []string {
+				s := record.(codeowners.Suggestion)
+				return []string{s.Pattern, s.Owner.String(), strconv.Itoa(s.Files), strconv.Itoa(s.Commits), strconv.FormatFloat(s.Confidence, 'f', 2, 64)}
+			},
+			table: func(w io.Writer) {
+				rel, ok := relativeToRoot(root, codeownersPath)
+				if !ok {
+					rel = codeownersPath
+				}
+				content, err := os.ReadFile(codeownersPath)
+				exitIf(err)
+				writeSuggestionPatch(w, rel, content, suggestions)
+			},
+		}
+		for _, s := range suggestions {
+			rep.records = append(rep.records, s)
+		}
+
+		out := newOutput(cmd)
+		defer out.Close()
+
+		exitIf(format.write(out, rep))
+
+		if len(unsuggested) > 0 {
+			fmt.Fprintf(os.Stderr, "%d unowned files have no suggestion: no owner made at least %.0f%% of their commits\n", len(unsuggested), opts.MinConfidence*100)
+		}
+	},
+}
+
+// gitHistory lists the authors of the commits reachable from HEAD, and the files they changed,
+// ignoring merges. With since, only commits more recent than that date are listed.
+func gitHistory(root, since string) ([]codeowners.Commit, error) {
+	args := []string{"-c", "core.quotePath=false", "log", "--no-merges", "--no-renames", "--name-only", "--pretty=format:%x1e%aE"}
+	if since != "" {
+		args = append(args, "--since="+since)
+	}
+
+	cmd := exec.Command("git", append(args, "HEAD", "--")...)
+	cmd.Dir = root
+	log, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+
+	commits := make([]codeowners.Commit, 0)
+	for _, record := range bytes.Split(log, []byte{0x1e}) {
+		scanner := bufio.NewScanner(bytes.NewReader(record))
+		if !scanner.Scan() {
+			continue
+		}
+
+		commit := codeowners.Commit{Author: scanner.Text()}
+		for scanner.Scan() {
+			if file := scanner.Text(); file != "" {
+				commit.Files = append(commit.Files, file)
+			}
+		}
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+// suggestionContext is the number of unchanged lines shown before the added rules in a patch.
+const suggestionContext = 3
+
+// writeSuggestionPatch writes a unified diff appending the suggested rules to the CODEOWNERS file
+// at path, relative to the repository root, with the given content.
+func writeSuggestionPatch(w io.Writer, path string, content []byte, suggestions []codeowners.Suggestion) {
+	if len(suggestions) == 0 {
+		return
+	}
+
+	text := string(content)
+	missingNewline := text != "" && !strings.HasSuffix(text, "\n")
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	start := len(lines) - suggestionContext
+	if start < 0 {
+		start = 0
+	}
+	context := lines[start:]
+
+	added := make([]string, 0, len(suggestions)+1)
+	added = append(added, "# Suggested from git history by co suggest\n")
+	for _, s := range suggestions {
+		added = append(added, fmt.Sprintf("%s # %.0f%% of %d commits\n", s.Rule.String(), s.Confidence*100, s.Commits))
+	}
+
+	oldCount, newCount := len(context), len(context)+len(added)
+	fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", path, path)
+	fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(start+1, oldCount), hunkRange(start+1, newCount))
+
+	for i, line := range context {
+		if i == len(context)-1 && missingNewline {
+			// The last line gains a newline, so it changes
+			fmt.Fprintf(w, "-%s\n\\ No newline at end of file\n+%s\n", line, line)
+			continue
+		}
+		fmt.Fprintf(w, " %s", line)
+	}
+	for _, line := range added {
+		fmt.Fprintf(w, "+%s", line)
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range starts at the line before it
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -1,10 +1,9 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 
 	codeowners "github.com/lukealbao/co"
 	"github.com/spf13/cobra"
@@ -12,6 +11,7 @@ import (
 
 func exitIf(err error) {
 	if err != nil {
+		closeActiveOutput()
 		fmt.Fprintf(os.Stderr, "error: %s\n", err)
 		os.Exit(1)
 	}
@@ -22,6 +22,10 @@ var whoCmd = &cobra.Command{
 	Short: "List code owners for file(s)",
 	Long: `List code owners for file(s)
 
+With no arguments, all files at HEAD are listed. Directory arguments expand to the files tracked
+in the git index beneath them; use --untracked to add untracked files that are not ignored, or
+--all-files to list everything on disk. Paths are reported relative to the repository root.
+
 Default format displays a table mapping files to the list of owners:
 
     path/to/file/a                            [@backend @infrastructure]
@@ -29,79 +33,123 @@ Default format displays a table mapping files to the list of owners:
 
 Note that unowned files are displayed as belonging to the dummy "(unowned)" group.
 
-JSON-formatted output displays an array of objects. Unowned files have a null owners list:
+JSON-formatted output displays an array of objects, with the type of each owner (team, username,
+email, group, or unowned for the "(unowned)" group) and, for teams, the organization:
 
     [
       {
         "path": "path/to/file/a",
-        "owners": ["@backend", "@infrastructure"]
+        "owners": [
+          {"name": "@org/backend", "type": "team", "org": "org"},
+          {"name": "@octocat", "type": "username"}
+        ]
       },
       {
         "path": "path/to/file/b",
-        "owners": null
+        "owners": [{"name": "(unowned)", "type": "unowned"}]
       }
     ]
+
+In Bitbucket files, given with --dialect bitbucket, groups are listed as @@Name. Use --reviewers to
+list the members of the groups selected as reviewers instead, by the selection strategy before
+them, such as random(2) or least_busy(1). Random selections are the same for the same --seed.
+
+Use --owner to only list some owners, by name, and --owner-type to only list owners of the given
+types, such as teams:
+
+    co who --owner-type team src/
+
+With --format, results can also be written as ndjson, csv or tsv, or with a Go template executed
+for each file:
+
+    co who --format=csv src/
+    co who --format='template={{.Path}}: {{join .Owners ","}}' src/
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		var err error
-		var filesToCheck []string
-
-		if len(args) > 0 {
-			filesToCheck = expandAllFiles(args[:])
-		} else {
-			filesToCheck, err = codeowners.LsFiles("HEAD")
-			exitIf(err)
+		filesToCheck, err := listFiles(args)
+		exitIf(err)
+		// Files ignored in the config file don't need owners
+		if showUnowned {
+			filesToCheck = coveredFiles(filesToCheck)
 		}
 
-		files, err := codeowners.ListOwners(sessionRules, filesToCheck, ownerFilters, showUnowned)
+		files, err := codeowners.ListOwnersContext(cmd.Context(), sessionRules, filesToCheck, listOptions())
 		exitIf(err)
+		if reviewers, err := cmd.Flags().GetBool("reviewers"); err != nil || reviewers {
+			exitIf(err)
+			exitIf(selectReviewers(cmd, files))
+		}
 
-		formatJson, err := cmd.Flags().GetBool("json")
+		format, err := commandFormat(cmd)
 		exitIf(err)
 
-		if formatJson {
-			bytes, err := json.MarshalIndent(files, "", "  ")
-			exitIf(err)
-
-			fmt.Fprintf(cmd.OutOrStdout(), "%s\n", bytes)
-			return
+		rep := report{
+			value:  files,
+			header: []string{"path", "owners"},
+			row: func(record interface{}) []string {
+				file := record.(*codeowners.FileOwners)
+				return []string{file.Path, ownerList(file.Owners)}
+			},
+			table: func(w io.Writer) {
+				for _, result := range files {
+					fmt.Fprintf(w, "%-70s %s\n", result.Path, result.Owners)
+				}
+			},
 		}
-
-		for _, result := range files {
-			fmt.Fprintf(cmd.OutOrStdout(), "%-70s %s\n", result.Path, result.Owners)
+		for _, file := range files {
+			rep.records = append(rep.records, file)
 		}
+
+		out := newOutput(cmd)
+		defer out.Close()
+
+		exitIf(format.write(out, rep))
 	},
 }
 
-func expandAllFiles(paths []string) []string {
-	out := make([]string, 0)
-
-	isDir := func(path string) bool {
-		info, err := os.Stat(path)
-		if os.IsNotExist(err) {
-			return false
-		}
-		return info.IsDir()
+// selectReviewers replaces the owners of each file with the reviewers selected from them. Files
+// are resolved in order with the same seed, so the same files always get the same reviewers.
+func selectReviewers(cmd *cobra.Command, files codeowners.Owners) error {
+	seed, err := cmd.Flags().GetInt64("seed")
+	if err != nil {
+		return err
 	}
 
-	for _, p := range paths {
-		if !isDir(p) {
-			out = append(out, p)
+	resolver := codeowners.NewResolver(sessionGroups, seed)
+	matcher := codeowners.NewMatcher(sessionRules)
+	for _, file := range files {
+		if len(file.Owners) == 1 && file.Owners[0] == codeowners.Unowned {
 			continue
 		}
+		rule, err := matcher.Match(file.Path)
+		if err != nil {
+			return err
+		}
+		var selections map[codeowners.Owner]codeowners.Selection
+		if rule != nil {
+			selections = rule.Selections
+		}
+		if file.Owners, err = resolver.Reviewers(file.Owners, selections); err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
 
-		filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
-			if path == ".git" {
-				return filepath.SkipDir
-			}
-
-			if !isDir(path) {
-				out = append(out, path)
-			}
-
-			return nil
-		})
+// listOptions returns the ListOwners options set by command-line flags.
+func listOptions() codeowners.ListOptions {
+	for _, t := range ownerTypes {
+		switch t {
+		case codeowners.TeamOwner, codeowners.UsernameOwner, codeowners.EmailOwner, codeowners.GroupOwner:
+		default:
+			exitIf(fmt.Errorf("invalid --owner-type %q: must be team, username, email or group", t))
+		}
 	}
 
-	return out
+	return codeowners.ListOptions{
+		OwnerFilters: ownerFilters,
+		OwnerTypes:   ownerTypes,
+		ShowUnowned:  showUnowned,
+		Workers:      workers,
+	}
 }
@@ -1,20 +1,15 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 
+	codeowners "github.com/lukealbao/co"
 	"github.com/spf13/cobra"
 )
 
-type filematch struct {
-	Path   string   `json:"path"`
-	Line   int      `json:"line"`
-	Rule   *string  `json:"rule"`
-	Owners []string `json:"owners"`
-}
-
 var whyCmd = &cobra.Command{
 	Use:   "why file",
 	Short: "Identify which rule effects ownership for a single file.",
@@ -33,15 +28,26 @@ JSON-formatted output displays an object that includes the file path:
     {
       "path": "backend/db/users.test.ts",
       "line": 42,
-      "owners": ["@backend", "@qa"]
+      "rule": "backend/**/*.test.ts",
+      "owners": [
+        {"name": "@org/backend", "type": "team", "org": "org"},
+        {"name": "qa@example.com", "type": "email"}
+      ]
     }
 
 If the file is unowned, the owners list will be null:
     {
       "path": "path/to/file/b",
       "line": -1,
+      "rule": null,
       "owners": null
     }
+
+Use --trace to list every rule matching the file, in order, ending with the one that takes effect.
+With --owners-files, rules come from several files, and each is shown with the file it came from:
+
+  OWNERS:2                       *                                                  [@org/core]
+  services/payments/OWNERS:1     /services/payments/                                [@org/payments @org/core]
 `,
 	Run: func(cmd *cobra.Command, files []string) {
 		if len(files) != 1 {
@@ -49,42 +55,77 @@ If the file is unowned, the owners list will be null:
 			os.Exit(1)
 		}
 
-		formatJson, err := cmd.Flags().GetBool("json")
+		format, err := commandFormat(cmd)
 		exitIf(err)
 
-		if formatJson {
-			match := filematch{Line: -1}
-
-			// Yes, files is actually restricted to len(1) above.
-			for _, file := range files {
-				rule, err := sessionRules.Match(file)
-				exitIf(err)
-
-				if rule != nil {
-					match.Path = file
-					match.Line = rule.SourceLine
-					pat := rule.RawPattern()
-					match.Rule = &pat
-					match.Owners = rule.Owners
-				}
-			}
-
-			bytes, err := json.MarshalIndent(match, "", "  ")
+		if trace, err := cmd.Flags().GetBool("trace"); err != nil {
 			exitIf(err)
-
-			fmt.Fprintf(cmd.OutOrStdout(), "%s\n", bytes)
+		} else if trace {
+			traceOwnership(cmd, format, files[0])
 			return
 		}
 
-		for _, file := range files {
-			rule, err := sessionRules.Match(file)
-			exitIf(err)
+		match, err := codeowners.ExplainOwnership(sessionRules, files[0])
+		exitIf(err)
 
-			if rule == nil {
-				fmt.Fprintf(cmd.OutOrStdout(), "  %4d %-70s %s\n", -1, "(no match)", "(unowned)")
-			} else {
-				fmt.Fprintf(cmd.OutOrStdout(), "  %4d %-70s %s\n", rule.SourceLine, rule.RawPattern(), rule.Owners)
-			}
+		rep := report{
+			value:   match,
+			records: []interface{}{match},
+			header:  []string{"path", "line", "rule", "owners"},
+			row: func(interface{}) []string {
+				rule := ""
+				if match.Rule != nil {
+					rule = *match.Rule
+				}
+				return []string{match.Path, strconv.Itoa(match.Line), rule, ownerList(match.Owners)}
+			},
+			table: func(w io.Writer) {
+				if match.Rule == nil {
+					fmt.Fprintf(w, "  %4d %-70s %s\n", -1, "(no match)", "(unowned)")
+				} else {
+					fmt.Fprintf(w, "  %4d %-70s %s\n", match.Line, *match.Rule, match.Owners)
+				}
+			},
 		}
+
+		out := newOutput(cmd)
+		defer out.Close()
+
+		exitIf(format.write(out, rep))
 	},
 }
+
+// traceOwnership prints every rule matching the path, with the file and line it came from.
+func traceOwnership(cmd *cobra.Command, format *formatter, path string) {
+	trace, err := codeowners.TraceOwnership(sessionRules, path)
+	exitIf(err)
+
+	rep := report{
+		value:  trace,
+		header: []string{"path", "file", "line", "rule", "owners"},
+		row: func(record interface{}) []string {
+			match := record.(codeowners.RuleMatch)
+			return []string{match.Path, match.File, strconv.Itoa(match.Line), *match.Rule, ownerList(match.Owners)}
+		},
+		table: func(w io.Writer) {
+			if len(trace) == 0 {
+				fmt.Fprintf(w, "  %-30s %-50s %s\n", "(no match)", "", "(unowned)")
+			}
+			for _, match := range trace {
+				source := strconv.Itoa(match.Line)
+				if match.File != "" {
+					source = match.File + ":" + source
+				}
+				fmt.Fprintf(w, "  %-30s %-50s %s\n", source, *match.Rule, match.Owners)
+			}
+		},
+	}
+	for _, match := range trace {
+		rep.records = append(rep.records, match)
+	}
+
+	out := newOutput(cmd)
+	defer out.Close()
+
+	exitIf(format.write(out, rep))
+}
@@ -3,9 +3,9 @@ package codeowners
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
@@ -13,43 +13,47 @@ import (
 // LoadFileFromStandardLocation loads and parses a CODEOWNERS file at one of the
 // standard locations for CODEOWNERS files (./, .github/, docs/). If run from a
 // git repository, all paths are relative to the repository root.
-func LoadFileFromStandardLocation() ([]Rule, error) {
+func LoadFileFromStandardLocation(opts ...ParseOption) ([]Rule, error) {
 	path := FindFileAtStandardLocation()
 	if path == "" {
 		return nil, fmt.Errorf("could not find CODEOWNERS file at any of the standard locations")
 	}
-	return LoadFile(path)
+	return LoadFile(path, opts...)
 }
 
 // LoadFile loads and parses a CODEOWNERS file at the path specified.
-func LoadFile(path string) ([]Rule, error) {
+func LoadFile(path string, opts ...ParseOption) ([]Rule, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	return ParseFile(f)
+	defer f.Close()
+	return ParseFile(f, opts...)
 }
 
+// LsFiles lists the files in the current git repository at ref, relative to the repository root.
+// If ref is an empty string, files in the git index are listed.
 func LsFiles(ref string) ([]string, error) {
-	var (
-		files []byte
-		err   error
-	)
-	if ref == "" {
-		files, err = exec.Command("git", "ls-files").Output()
-	} else {
-		files, err = exec.Command("git", "ls-tree", "-r", "--name-only", ref).Output()
-	}
+	repo, err := OpenRepository(".")
 	if err != nil {
 		return nil, err
 	}
+	return repo.LsFiles(ref)
+}
 
-	return strings.Split(strings.TrimSpace(string(files)), "\n"), nil
+// LsUntrackedFiles lists files in the current git repository's working tree that are neither
+// tracked nor ignored, relative to the repository root.
+func LsUntrackedFiles() ([]string, error) {
+	repo, err := OpenRepository(".")
+	if err != nil {
+		return nil, err
+	}
+	return repo.LsUntrackedFiles()
 }
 
-func LoadFileFromStandardLocationAtRef(ref string) ([]Rule, error) {
+func LoadFileFromStandardLocationAtRef(ref string, opts ...ParseOption) ([]Rule, error) {
 	if ref == "" {
-		return LoadFileFromStandardLocation()
+		return LoadFileFromStandardLocation(opts...)
 	}
 
 	files, err := LsFiles(ref)
@@ -57,12 +61,10 @@ func LoadFileFromStandardLocationAtRef(ref string) ([]Rule, error) {
 		return nil, err
 	}
 
-	standards := []string{"CODEOWNERS", ".github/CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"}
-
 	for _, file := range files {
-		for _, known := range standards {
+		for _, known := range standardLocations {
 			if file == known {
-				return LoadFileAtRef(ref, file)
+				return LoadFileAtRef(ref, file, opts...)
 			}
 		}
 	}
@@ -72,17 +74,29 @@ func LoadFileFromStandardLocationAtRef(ref string) ([]Rule, error) {
 
 // LoadFileAtRef loads and parses a CODEOWNERS file from a historical commit. If ref is an empty string,
 // file will be read from disk.
-func LoadFileAtRef(ref, path string) ([]Rule, error) {
+func LoadFileAtRef(ref, path string, opts ...ParseOption) ([]Rule, error) {
 	if ref == "" {
-		return LoadFile(path)
+		return LoadFile(path, opts...)
+	}
+
+	repo, err := OpenRepository(".")
+	if err != nil {
+		return nil, err
 	}
 
-	spec := fmt.Sprintf("%s:%s", ref, path)
-	f, err := exec.Command("git", "show", spec).Output()
+	// Paths in a tree are relative to the repository root
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(repo.Root(), path); err == nil {
+			path = rel
+		}
+	}
+	path = filepath.ToSlash(path)
+
+	f, err := repo.ReadFile(ref, path)
 	if err != nil {
-		return nil, fmt.Errorf("%s: could not load codeowners at %s", err, spec)
+		return nil, fmt.Errorf("%s: could not load codeowners at %s:%s", err, ref, path)
 	}
-	return ParseFile(bytes.NewReader(f))
+	return ParseFile(bytes.NewReader(f), opts...)
 }
 
 // FindFileAtStandardLocation loops through the standard locations for
@@ -96,33 +110,21 @@ func FindFileAtStandardLocation() string {
 		pathPrefix = repoRoot
 	}
 
-	for _, path := range []string{"CODEOWNERS", ".github/CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"} {
-		fullPath := filepath.Join(pathPrefix, path)
-		if fileExists(fullPath) {
-			return fullPath
-		}
+	if path := FindFileFS(os.DirFS(filepath.Join(pathPrefix, "."))); path != "" {
+		return filepath.Join(pathPrefix, filepath.FromSlash(path))
 	}
 	return ""
 }
 
-// fileExist checks if a normal file exists at the path specified.
-func fileExists(path string) bool {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return false
-	}
-	return !info.IsDir()
-}
-
 // findRepositoryRoot returns the path to the root of the git repository, if
 // we're currently in one. If we're not in a git repository, the boolean return
 // value is false.
 func findRepositoryRoot() (string, bool) {
-	output, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	repo, err := OpenRepository(".")
 	if err != nil {
 		return "", false
 	}
-	return strings.TrimSpace(string(output)), true
+	return repo.Root(), true
 }
 
 const (
@@ -132,22 +134,104 @@ const (
 	TeamOwner string = "team"
 	// UsernameOwner is the owner type for GitHub usernames.
 	UsernameOwner string = "username"
+	// GroupOwner is the owner type for Bitbucket groups, written @@Name.
+	GroupOwner string = "group"
+	// NoOwner is the owner type of the "(unowned)" group that unowned files are reported under.
+	NoOwner string = "unowned"
+)
+
+// Unowned is the dummy owner that unowned files belong to in ListOwners and stats output.
+var Unowned = Owner{Value: "(unowned)", Type: NoOwner}
+
+// Strategies for selecting reviewers from owners in Bitbucket files.
+const (
+	// StrategyAll makes every owner a reviewer, as when no strategy is given.
+	StrategyAll = "all"
+	// StrategyRandom picks reviewers at random.
+	StrategyRandom = "random"
+	// StrategyLeastBusy picks the reviewers with the fewest open reviews.
+	StrategyLeastBusy = "least_busy"
 )
 
+// Selection is the strategy for selecting reviewers from owners, and how many to select. The zero
+// value makes every owner a reviewer.
+type Selection struct {
+	Strategy string
+	Count    int
+}
+
+// String returns the selection as written in a Bitbucket file, such as random(2).
+func (s Selection) String() string {
+	if s.Strategy == "" || s.Strategy == StrategyAll {
+		return s.Strategy
+	}
+	return fmt.Sprintf("%s(%d)", s.Strategy, s.Count)
+}
+
 // Owner represents an owner found in a rule.
 type Owner struct {
-	// Value is the name of the owner: the email addres, team name, or username.
+	// Value is the name of the owner: the email addres, team name, username, or group name.
 	Value string
-	// Type will be one of 'email', 'team', or 'username'.
+	// Type will be one of 'email', 'team', 'username', 'group', or 'unowned' for the Unowned
+	// group.
 	Type string
 }
 
 // String returns a string representation of the owner. For email owners, it
 // simply returns the email address. For user and team owners it prepends an '@'
-// to the owner.
+// to the owner, and for groups '@@'.
 func (o Owner) String() string {
-	if o.Type == EmailOwner {
+	switch o.Type {
+	case EmailOwner, NoOwner:
 		return o.Value
+	case GroupOwner:
+		return "@@" + o.Value
 	}
 	return "@" + o.Value
 }
+
+// Org returns the organization of a team owner, or an empty string for other owners.
+func (o Owner) Org() string {
+	if o.Type != TeamOwner {
+		return ""
+	}
+	org, _, _ := strings.Cut(o.Value, "/")
+	return org
+}
+
+type ownerJSON struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Org  string `json:"org,omitempty"`
+}
+
+// MarshalJSON encodes the owner as an object with its name as written in CODEOWNERS, its type
+// and, for teams, its organization.
+func (o Owner) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ownerJSON{Name: o.String(), Type: o.Type, Org: o.Org()})
+}
+
+// UnmarshalJSON decodes an owner from the object written by MarshalJSON, or from a plain string
+// as written in CODEOWNERS.
+func (o *Owner) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var obj ownerJSON
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		name = obj.Name
+	}
+
+	if name == Unowned.Value {
+		*o = Unowned
+		return nil
+	}
+
+	owner, err := newAnyOwner(name)
+	if err != nil {
+		return err
+	}
+	*o = owner
+	return nil
+}
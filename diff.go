@@ -0,0 +1,247 @@
+package codeowners
+
+import (
+	"context"
+	"sort"
+)
+
+// ChangeKind describes how a file changed between two versions of a repository.
+type ChangeKind string
+
+const (
+	// FileAdded is a file that only exists in the newer version.
+	FileAdded ChangeKind = "added"
+	// FileRemoved is a file that only exists in the older version.
+	FileRemoved ChangeKind = "removed"
+	// FileRenamed is a file that was moved, whether or not its owners changed.
+	FileRenamed ChangeKind = "renamed"
+	// OwnersChanged is a file that exists in both versions with different owners.
+	OwnersChanged ChangeKind = "modified"
+)
+
+// OwnershipChange describes the change in ownership of a single file. Unowned files have an empty
+// owners list.
+type OwnershipChange struct {
+	Kind      ChangeKind `json:"kind"`
+	Path      string     `json:"path"`
+	OldPath   string     `json:"oldPath,omitempty"`
+	OldOwners []Owner    `json:"oldOwners"`
+	NewOwners []Owner    `json:"newOwners"`
+	Gained    []Owner    `json:"gained"`
+	Lost      []Owner    `json:"lost"`
+}
+
+// DiffOptions controls how DiffOwnership matches files between versions.
+type DiffOptions struct {
+	// Renames maps paths in the older version to their paths in the newer version.
+	Renames map[string]string
+	// Workers is the number of files checked concurrently. Zero means one per CPU.
+	Workers int
+}
+
+// DiffOwnership compares the ownership of two versions of a repository, each described by its
+// rules and its list of files, and returns the files whose ownership changed, sorted by path.
+func DiffOwnership(ctx context.Context, from Ruleset, fromFiles []string, to Ruleset, toFiles []string, opts DiffOptions) ([]OwnershipChange, error) {
+	before, err := ownersByPath(ctx, from, fromFiles, opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+	after, err := ownersByPath(ctx, to, toFiles, opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]OwnershipChange, 0)
+	seen := make(map[string]bool, len(after))
+
+	for _, path := range fromFiles {
+		oldOwners := before[path]
+
+		if renamed, ok := opts.Renames[path]; ok && renamed != path {
+			_, stillExists := after[path]
+			if newOwners, ok := after[renamed]; ok && !stillExists {
+				seen[renamed] = true
+				changes = append(changes, newChange(FileRenamed, renamed, path, oldOwners, newOwners))
+				continue
+			}
+		}
+
+		newOwners, ok := after[path]
+		switch {
+		case !ok:
+			changes = append(changes, newChange(FileRemoved, path, "", oldOwners, []Owner{}))
+		case !sameOwners(oldOwners, newOwners):
+			changes = append(changes, newChange(OwnersChanged, path, "", oldOwners, newOwners))
+		}
+		seen[path] = true
+	}
+
+	for _, path := range toFiles {
+		if _, existed := before[path]; !existed && !seen[path] {
+			changes = append(changes, newChange(FileAdded, path, "", []Owner{}, after[path]))
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// OwnerDiffStat counts the files an owner gained and lost between two versions.
+type OwnerDiffStat struct {
+	Owner  Owner `json:"owner"`
+	Gained int   `json:"gained"`
+	Lost   int   `json:"lost"`
+}
+
+// OwnershipTransfer counts the files that moved from one owner to another between two versions.
+type OwnershipTransfer struct {
+	From  Owner `json:"from"`
+	To    Owner `json:"to"`
+	Count int   `json:"fileCount"`
+}
+
+// DiffStat summarizes a list of ownership changes per owner.
+type DiffStat struct {
+	ChangedFiles int                 `json:"changedFiles"`
+	Owners       []OwnerDiffStat     `json:"owners"`
+	Transfers    []OwnershipTransfer `json:"transfers"`
+}
+
+// SummarizeOwnershipDiff counts, for each owner, the files gained and lost in a diff, along with a
+// matrix of transfers between owners. Unowned files are counted against the Unowned group, as in
+// CalculateOwnershipStats. Files that were added or removed count as gains or losses, but not as
+// transfers. Owners are sorted by the number of files affected, and transfers by file count.
+func SummarizeOwnershipDiff(changes []OwnershipChange) DiffStat {
+	stats := make(map[Owner]*OwnerDiffStat)
+	stat := func(owner Owner) *OwnerDiffStat {
+		if _, ok := stats[owner]; !ok {
+			stats[owner] = &OwnerDiffStat{Owner: owner}
+		}
+		return stats[owner]
+	}
+	transfers := make(map[[2]Owner]int)
+
+	orUnowned := func(changed, all []Owner) []Owner {
+		if len(all) == 0 {
+			return []Owner{Unowned}
+		}
+		return changed
+	}
+
+	changed := 0
+	for _, change := range changes {
+		gained := orUnowned(change.Gained, change.NewOwners)
+		lost := orUnowned(change.Lost, change.OldOwners)
+
+		switch change.Kind {
+		case FileAdded:
+			lost = nil
+		case FileRemoved:
+			gained = nil
+		default:
+			if len(change.Gained) == 0 && len(change.Lost) == 0 {
+				continue // renamed without changing owners
+			}
+			for _, from := range lost {
+				for _, to := range gained {
+					transfers[[2]Owner{from, to}]++
+				}
+			}
+		}
+
+		changed++
+		for _, owner := range gained {
+			stat(owner).Gained++
+		}
+		for _, owner := range lost {
+			stat(owner).Lost++
+		}
+	}
+
+	out := DiffStat{
+		ChangedFiles: changed,
+		Owners:       make([]OwnerDiffStat, 0, len(stats)),
+		Transfers:    make([]OwnershipTransfer, 0, len(transfers)),
+	}
+	for _, s := range stats {
+		out.Owners = append(out.Owners, *s)
+	}
+	for pair, count := range transfers {
+		out.Transfers = append(out.Transfers, OwnershipTransfer{From: pair[0], To: pair[1], Count: count})
+	}
+
+	sort.Slice(out.Owners, func(i, j int) bool {
+		a, b := out.Owners[i], out.Owners[j]
+		if a.Gained+a.Lost != b.Gained+b.Lost {
+			return a.Gained+a.Lost > b.Gained+b.Lost
+		}
+		return a.Owner.String() < b.Owner.String()
+	})
+	sort.Slice(out.Transfers, func(i, j int) bool {
+		a, b := out.Transfers[i], out.Transfers[j]
+		switch {
+		case a.Count != b.Count:
+			return a.Count > b.Count
+		case a.From != b.From:
+			return a.From.String() < b.From.String()
+		}
+		return a.To.String() < b.To.String()
+	})
+
+	return out
+}
+
+func newChange(kind ChangeKind, path, oldPath string, oldOwners, newOwners []Owner) OwnershipChange {
+	return OwnershipChange{
+		Kind:      kind,
+		Path:      path,
+		OldPath:   oldPath,
+		OldOwners: oldOwners,
+		NewOwners: newOwners,
+		Gained:    ownersDifference(newOwners, oldOwners),
+		Lost:      ownersDifference(oldOwners, newOwners),
+	}
+}
+
+// ownersByPath maps each file to its owners, with an empty list for unowned files.
+func ownersByPath(ctx context.Context, rules Ruleset, files []string, workers int) (map[string][]Owner, error) {
+	owned, err := ListOwnersContext(ctx, rules, files, ListOptions{Workers: workers})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]Owner, len(owned))
+	for _, file := range owned {
+		if len(file.Owners) == 1 && file.Owners[0] == Unowned {
+			out[file.Path] = []Owner{}
+			continue
+		}
+		out[file.Path] = file.Owners
+	}
+	return out, nil
+}
+
+// ownersDifference returns the owners in a that are not in b, in the order they appear in a.
+func ownersDifference(a, b []Owner) []Owner {
+	out := make([]Owner, 0)
+	for _, owner := range a {
+		found := false
+		for _, other := range b {
+			if owner == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, owner)
+		}
+	}
+	return out
+}
+
+// sameOwners reports whether a and b hold the same owners, in any order.
+func sameOwners(a, b []Owner) bool {
+	return len(ownersDifference(a, b)) == 0 && len(ownersDifference(b, a)) == 0
+}
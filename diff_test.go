@@ -0,0 +1,138 @@
+package codeowners
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, file string) Ruleset {
+	rules, err := ParseFile(strings.NewReader(file))
+	require.NoError(t, err)
+	return rules
+}
+
+func TestDiffOwnership(t *testing.T) {
+	from := mustParse(t, `* @org/a
+/api/ @org/api
+/docs/
+`)
+	to := mustParse(t, `* @org/a
+/api/ @org/b @org/api
+/docs/ @org/docs
+`)
+
+	fromFiles := []string{"README.md", "api/server.go", "api/old.go", "docs/index.md", "removed.txt", "moved.go"}
+	toFiles := []string{"README.md", "api/server.go", "api/new.go", "docs/index.md", "added.txt", "api/moved.go"}
+
+	changes, err := DiffOwnership(context.Background(), from, fromFiles, to, toFiles, DiffOptions{
+		Renames: map[string]string{"moved.go": "api/moved.go"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []OwnershipChange{
+		{
+			Kind:      FileAdded,
+			Path:      "added.txt",
+			OldOwners: []Owner{},
+			NewOwners: mustOwners(t, "@org/a"),
+			Gained:    mustOwners(t, "@org/a"),
+			Lost:      []Owner{},
+		},
+		{
+			Kind:      FileRenamed,
+			Path:      "api/moved.go",
+			OldPath:   "moved.go",
+			OldOwners: mustOwners(t, "@org/a"),
+			NewOwners: mustOwners(t, "@org/b", "@org/api"),
+			Gained:    mustOwners(t, "@org/b", "@org/api"),
+			Lost:      mustOwners(t, "@org/a"),
+		},
+		{
+			Kind:      FileAdded,
+			Path:      "api/new.go",
+			OldOwners: []Owner{},
+			NewOwners: mustOwners(t, "@org/b", "@org/api"),
+			Gained:    mustOwners(t, "@org/b", "@org/api"),
+			Lost:      []Owner{},
+		},
+		{
+			Kind:      FileRemoved,
+			Path:      "api/old.go",
+			OldOwners: mustOwners(t, "@org/api"),
+			NewOwners: []Owner{},
+			Gained:    []Owner{},
+			Lost:      mustOwners(t, "@org/api"),
+		},
+		{
+			Kind:      OwnersChanged,
+			Path:      "api/server.go",
+			OldOwners: mustOwners(t, "@org/api"),
+			NewOwners: mustOwners(t, "@org/b", "@org/api"),
+			Gained:    mustOwners(t, "@org/b"),
+			Lost:      []Owner{},
+		},
+		{
+			Kind:      OwnersChanged,
+			Path:      "docs/index.md",
+			OldOwners: []Owner{},
+			NewOwners: mustOwners(t, "@org/docs"),
+			Gained:    mustOwners(t, "@org/docs"),
+			Lost:      []Owner{},
+		},
+		{
+			Kind:      FileRemoved,
+			Path:      "removed.txt",
+			OldOwners: mustOwners(t, "@org/a"),
+			NewOwners: []Owner{},
+			Gained:    []Owner{},
+			Lost:      mustOwners(t, "@org/a"),
+		},
+	}, changes)
+}
+
+func TestDiffOwnershipUnchanged(t *testing.T) {
+	rules := mustParse(t, "* @org/a\n")
+	files := []string{"a.go", "b.go"}
+
+	changes, err := DiffOwnership(context.Background(), rules, files, rules, files, DiffOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Reordering owners changes nothing
+	reordered := mustParse(t, "* @org/a\n/b.go @org/b @org/c\n")
+	changes, err = DiffOwnership(context.Background(), reordered, files, mustParse(t, "* @org/a\n/b.go @org/c @org/b\n"), files, DiffOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestSummarizeOwnershipDiff(t *testing.T) {
+	changes := []OwnershipChange{
+		newChange(OwnersChanged, "a.go", "", mustOwners(t, "@a"), mustOwners(t, "@b")),
+		newChange(OwnersChanged, "b.go", "", mustOwners(t, "@a"), mustOwners(t, "@b")),
+		newChange(OwnersChanged, "c.go", "", mustOwners(t, "@a", "@c"), mustOwners(t, "@b", "@c")),
+		newChange(OwnersChanged, "d.go", "", []Owner{}, mustOwners(t, "@c")),
+		newChange(OwnersChanged, "e.go", "", mustOwners(t, "@c"), mustOwners(t, "@c", "@d")),
+		newChange(FileRenamed, "f.go", "old/f.go", mustOwners(t, "@a"), mustOwners(t, "@a")),
+		newChange(FileAdded, "g.go", "", []Owner{}, mustOwners(t, "@b")),
+		newChange(FileRemoved, "h.go", "", mustOwners(t, "@a"), []Owner{}),
+	}
+
+	assert.Equal(t, DiffStat{
+		ChangedFiles: 7,
+		Owners: []OwnerDiffStat{
+			{Owner: mustOwners(t, "@a")[0], Gained: 0, Lost: 4},
+			{Owner: mustOwners(t, "@b")[0], Gained: 4, Lost: 0},
+			{Owner: Unowned, Gained: 0, Lost: 1},
+			{Owner: mustOwners(t, "@c")[0], Gained: 1, Lost: 0},
+			{Owner: mustOwners(t, "@d")[0], Gained: 1, Lost: 0},
+		},
+		Transfers: []OwnershipTransfer{
+			{From: mustOwners(t, "@a")[0], To: mustOwners(t, "@b")[0], Count: 3},
+			{From: Unowned, To: mustOwners(t, "@c")[0], Count: 1},
+		},
+	}, SummarizeOwnershipDiff(changes))
+}
@@ -0,0 +1,314 @@
+package codeowners
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NewRule creates a rule mapping a gitignore-style pattern to owners. The pattern is validated as
+// it would be when parsing a CODEOWNERS file, and so are the owners, which may be written in any
+// dialect but can't include Unowned. Rules without owners are allowed, and leave matching files unowned.
+func NewRule(pattern string, owners ...Owner) (Rule, error) {
+	return NewRuleWith(pattern, owners)
+}
+
+// NewRuleWith creates a rule like NewRule, validating the pattern with the parse options, so that
+// it may be an exclusion in dialects supporting them, or use the full gitignore syntax.
+func NewRuleWith(pattern string, owners []Owner, opts ...ParseOption) (Rule, error) {
+	for i, ch := range pattern {
+		if isWhitespace(ch) && (i == 0 || pattern[i-1] != '\\') {
+			return Rule{}, fmt.Errorf("invalid pattern %q: whitespace must be escaped", pattern)
+		}
+	}
+
+	r := newRule()
+	if err := parseDialectRule(pattern, r, newParseOptions(opts)); err != nil {
+		return Rule{}, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	if r.RawPattern() != pattern || r.trailingComment != "" {
+		return Rule{}, fmt.Errorf("invalid pattern %q", pattern)
+	}
+
+	if err := checkRuleOwners(r, owners); err != nil {
+		return Rule{}, err
+	}
+	r.Owners = append(r.Owners, owners...)
+	return *r, nil
+}
+
+// checkRuleOwners checks that the owners are valid, and that the rule can have them.
+func checkRuleOwners(r *Rule, owners []Owner) error {
+	if r.Negated && len(owners) > 0 {
+		return fmt.Errorf("exclusion %s can't have owners", r.RawPattern())
+	}
+	for _, owner := range owners {
+		parsed, err := newAnyOwner(owner.String())
+		if err != nil || parsed != owner {
+			return fmt.Errorf("invalid owner %q", owner.String())
+		}
+	}
+	return nil
+}
+
+// Index returns the position of the last rule with the pattern, or -1 if there is none. Only the
+// last such rule has any effect.
+func (r Ruleset) Index(pattern string) int {
+	for i := len(r) - 1; i >= 0; i-- {
+		if r[i].RawPattern() == pattern {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert adds a rule at position i, moving later rules down. Since the last matching rule takes
+// precedence, a rule inserted before others may be overridden by them.
+func (r *Ruleset) Insert(i int, rule Rule) error {
+	if i < 0 || i > len(*r) {
+		return fmt.Errorf("position %d out of range [0, %d]", i, len(*r))
+	}
+
+	*r = append(*r, Rule{})
+	copy((*r)[i+1:], (*r)[i:])
+	(*r)[i] = rule
+	return nil
+}
+
+// InsertAfter adds a rule directly after the last rule matching path, which is the rule currently
+// determining its ownership, so related rules stay together. If no rule matches, the rule is added
+// at the end. It returns the position of the new rule.
+func (r *Ruleset) InsertAfter(path string, rule Rule) (int, error) {
+	i := len(*r)
+	for j := len(*r) - 1; j >= 0; j-- {
+		match, err := (*r)[j].Match(path)
+		if err != nil {
+			return -1, err
+		}
+		if match {
+			i = j + 1
+			break
+		}
+	}
+
+	return i, r.Insert(i, rule)
+}
+
+// Update replaces the owners of the last rule with the pattern, along with any reviewer selections
+// or section default owners it had.
+func (r Ruleset) Update(pattern string, owners ...Owner) error {
+	i := r.Index(pattern)
+	if i < 0 {
+		return fmt.Errorf("no rule for pattern %q", pattern)
+	}
+
+	if err := checkRuleOwners(&r[i], owners); err != nil {
+		return err
+	}
+	r[i].Owners = append(make([]Owner, 0, len(owners)), owners...)
+	r[i].Selections = nil
+	r[i].defaultOwners = false
+	return nil
+}
+
+// Delete removes the last rule with the pattern, returning it. Comments above the rule are kept
+// by moving them to the rule that follows.
+func (r *Ruleset) Delete(pattern string) (Rule, error) {
+	i := r.Index(pattern)
+	if i < 0 {
+		return Rule{}, fmt.Errorf("no rule for pattern %q", pattern)
+	}
+	return r.deleteAt(i), nil
+}
+
+// deleteAt removes the rule at index i, moving its comments to the rule that follows.
+func (r *Ruleset) deleteAt(i int) Rule {
+	deleted := (*r)[i]
+	if i+1 < len(*r) {
+		(*r)[i+1].leadingComment = deleted.leadingComment + (*r)[i+1].leadingComment
+	}
+	*r = append((*r)[:i], (*r)[i+1:]...)
+	return deleted
+}
+
+// Document is a CODEOWNERS file that can be edited and written back without losing its comments.
+type Document struct {
+	Rules Ruleset
+	// trailer is the comments and blank lines after the last rule.
+	trailer string
+}
+
+// ParseDocument parses a CODEOWNERS file for editing.
+func ParseDocument(f io.Reader, opts ...ParseOption) (*Document, error) {
+	rules, trailer, err := parse(f, newParseOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Rules: rules, trailer: trailer}, nil
+}
+
+// Delete removes the last rule with the pattern, as Ruleset.Delete, keeping the comments above it
+// even when it is the last rule.
+func (d *Document) Delete(pattern string) (Rule, error) {
+	last := d.Rules.Index(pattern) == len(d.Rules)-1
+
+	deleted, err := d.Rules.Delete(pattern)
+	if err == nil && last {
+		d.trailer = deleted.leadingComment + d.trailer
+	}
+	return deleted, err
+}
+
+// DeleteLine removes the rule parsed from a line of the file, keeping its comments like Delete.
+// Unlike Delete, it can remove any of several rules with the same pattern.
+func (d *Document) DeleteLine(line int) (Rule, error) {
+	for i, rule := range d.Rules {
+		if rule.SourceLine != line {
+			continue
+		}
+		deleted := d.Rules.deleteAt(i)
+		if i == len(d.Rules) {
+			d.trailer = deleted.leadingComment + d.trailer
+		}
+		return deleted, nil
+	}
+	return Rule{}, fmt.Errorf("no rule on line %d", line)
+}
+
+// WriteTo writes the document in CODEOWNERS format, one rule per line with its comments.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+
+	for _, rule := range d.Rules {
+		written, err := bw.WriteString(rule.String() + "\n")
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	written, err := bw.WriteString(d.trailer)
+	n += int64(written)
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// String returns the document in CODEOWNERS format.
+func (d *Document) String() string {
+	var b strings.Builder
+	d.WriteTo(&b)
+	return b.String()
+}
+
+// RuleChange describes a rule whose owners were changed by ReplaceOwners.
+type RuleChange struct {
+	Line      int     `json:"line"`
+	Pattern   string  `json:"pattern"`
+	OldOwners []Owner `json:"oldOwners"`
+	NewOwners []Owner `json:"newOwners"`
+}
+
+// Ownerless reports whether the change left a rule that had owners without any, so that the files
+// it matches are now unowned.
+func (c RuleChange) Ownerless() bool {
+	return len(c.NewOwners) == 0 && len(c.OldOwners) > 0
+}
+
+// ReplaceOwners replaces owners in every rule according to the mapping, where an owner mapped to
+// no owners is removed. Owner lists are deduplicated, keeping the first occurrence of each owner.
+// Replacements keep the reviewer selection of the owner they replace. It returns the rules that
+// changed, in order.
+func (r Ruleset) ReplaceOwners(mapping map[Owner][]Owner) []RuleChange {
+	changes := make([]RuleChange, 0)
+
+	for i := range r {
+		rule := &r[i]
+
+		owners := make([]Owner, 0, len(rule.Owners))
+		var selections map[Owner]Selection
+		seen := make(map[Owner]bool, len(rule.Owners))
+		add := func(owner Owner, selection Selection) {
+			if seen[owner] {
+				return
+			}
+			seen[owner] = true
+			owners = append(owners, owner)
+			if selection != (Selection{}) {
+				if selections == nil {
+					selections = make(map[Owner]Selection)
+				}
+				selections[owner] = selection
+			}
+		}
+
+		for _, owner := range rule.Owners {
+			replacements, ok := mapping[owner]
+			if !ok {
+				add(owner, rule.Selections[owner])
+				continue
+			}
+			for _, replacement := range replacements {
+				add(replacement, rule.Selections[owner])
+			}
+		}
+
+		if sameOwners(owners, rule.Owners) {
+			continue
+		}
+
+		changes = append(changes, RuleChange{
+			Line:      rule.SourceLine,
+			Pattern:   rule.RawPattern(),
+			OldOwners: rule.Owners,
+			NewOwners: owners,
+		})
+		rule.Owners = owners
+		rule.Selections = selections
+		rule.defaultOwners = false
+	}
+
+	return changes
+}
+
+// ParseOwnerMapping parses a mapping of owners for ReplaceOwners. Each line holds an owner
+// followed by the owners replacing it, if any, separated by whitespace; an owner on its own is
+// removed. Blank lines and lines starting with # are ignored:
+//
+//	# Payments was split in two
+//	@org/payments @org/billing @org/checkout
+//	@org/old-team @org/new-team
+//	@departed-user
+//
+// Owners are parsed in the dialect given by the options, if any.
+func ParseOwnerMapping(f io.Reader, opts ...ParseOption) (map[Owner][]Owner, error) {
+	o := newParseOptions(opts)
+	mapping := make(map[Owner][]Owner)
+	scanner := bufio.NewScanner(f)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		owners := make([]Owner, 0, len(fields))
+		for _, field := range fields {
+			owner, err := newDialectOwner(field, o.dialect)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			owners = append(owners, owner)
+		}
+
+		if _, ok := mapping[owners[0]]; ok {
+			return nil, fmt.Errorf("line %d: %s is mapped more than once", lineNo, owners[0])
+		}
+		mapping[owners[0]] = owners[1:]
+	}
+
+	return mapping, scanner.Err()
+}
@@ -0,0 +1,276 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRule(t *testing.T) {
+	rule, err := NewRule("/services/payments/", mustOwners(t, "@org/payments", "pay@example.com")...)
+	require.NoError(t, err)
+	assert.Equal(t, "/services/payments/ @org/payments pay@example.com", rule.String())
+
+	match, err := rule.Match("services/payments/main.go")
+	require.NoError(t, err)
+	assert.True(t, match)
+
+	rule, err = NewRule(`docs/with\ space`)
+	require.NoError(t, err)
+	assert.Equal(t, `docs/with\ space`, rule.String())
+
+	_, err = NewRule("docs/with space")
+	assert.EqualError(t, err, `invalid pattern "docs/with space": whitespace must be escaped`)
+
+	_, err = NewRule("docs/#comment")
+	assert.EqualError(t, err, `invalid pattern "docs/#comment"`)
+
+	_, err = NewRule("")
+	assert.EqualError(t, err, `invalid pattern "": unexpected end of rule`)
+
+	_, err = NewRule("docs/", Unowned)
+	assert.EqualError(t, err, `invalid owner "(unowned)"`)
+
+	_, err = NewRule("docs/", Owner{Value: "org/team", Type: UsernameOwner})
+	assert.EqualError(t, err, `invalid owner "@org/team"`)
+
+	// Parse options allow exclusions and gitignore patterns
+	_, err = NewRule("!/docs/")
+	assert.EqualError(t, err, `invalid pattern "!/docs/": negation is not supported by GitHub`)
+
+	rule, err = NewRuleWith("!/docs/", nil, WithDialect(DialectGitLab))
+	require.NoError(t, err)
+	assert.True(t, rule.Negated)
+	assert.Equal(t, "!/docs/", rule.String())
+
+	_, err = NewRuleWith("!/docs/", mustOwners(t, "@org/docs"), WithDialect(DialectGitLab))
+	assert.EqualError(t, err, "exclusion !/docs/ can't have owners")
+
+	_, err = NewRule("*.[ch]", mustOwners(t, "@org/c")...)
+	assert.Error(t, err)
+
+	rule, err = NewRuleWith("*.[ch]", mustOwners(t, "@org/c"), WithGitignorePatterns())
+	require.NoError(t, err)
+	match, err = rule.Match("src/main.h")
+	require.NoError(t, err)
+	assert.True(t, match)
+
+	// Rules are updated without checking their patterns again
+	rules := Ruleset{rule}
+	require.NoError(t, rules.Update("*.[ch]", mustOwners(t, "@org/core")...))
+	assert.Equal(t, "*.[ch] @org/core", rules[0].String())
+}
+
+func TestRulesetEditing(t *testing.T) {
+	rules := mustParse(t, `* @org/default
+/services/ @org/services
+/docs/ @org/docs
+/services/api/ @org/api
+`)
+
+	patterns := func() []string {
+		out := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			out = append(out, rule.RawPattern())
+		}
+		return out
+	}
+
+	assert.Equal(t, 1, rules.Index("/services/"))
+	assert.Equal(t, -1, rules.Index("/missing/"))
+
+	rule, err := NewRule("/services/payments/", mustOwners(t, "@org/payments")...)
+	require.NoError(t, err)
+	i, err := rules.InsertAfter("services/payments", rule)
+	require.NoError(t, err)
+	assert.Equal(t, 2, i)
+	assert.Equal(t, []string{"*", "/services/", "/services/payments/", "/docs/", "/services/api/"}, patterns())
+
+	rule, err = NewRule("/first/")
+	require.NoError(t, err)
+	require.NoError(t, rules.Insert(0, rule))
+	assert.Equal(t, "/first/", rules[0].RawPattern())
+	assert.EqualError(t, rules.Insert(10, rule), "position 10 out of range [0, 6]")
+
+	require.NoError(t, rules.Update("/docs/", mustOwners(t, "@org/writers")...))
+	assert.Equal(t, mustOwners(t, "@org/writers"), rules[rules.Index("/docs/")].Owners)
+	assert.EqualError(t, rules.Update("/missing/"), `no rule for pattern "/missing/"`)
+
+	deleted, err := rules.Delete("/services/")
+	require.NoError(t, err)
+	assert.Equal(t, mustOwners(t, "@org/services"), deleted.Owners)
+	assert.Equal(t, []string{"/first/", "*", "/services/payments/", "/docs/", "/services/api/"}, patterns())
+	_, err = rules.Delete("/services/")
+	assert.EqualError(t, err, `no rule for pattern "/services/"`)
+}
+
+func TestDocument(t *testing.T) {
+	file := `# Default owners
+* @org/default
+
+# Services
+/services/ @org/services # everything else
+/services/api/ @org/api
+
+# Generated below this line
+`
+
+	doc, err := ParseDocument(strings.NewReader(file))
+	require.NoError(t, err)
+	assert.Equal(t, file, doc.String())
+
+	_, err = doc.Delete("/services/")
+	require.NoError(t, err)
+	assert.Equal(t, `# Default owners
+* @org/default
+
+# Services
+/services/api/ @org/api
+
+# Generated below this line
+`, doc.String())
+
+	_, err = doc.Delete("/services/api/")
+	require.NoError(t, err)
+	rule, err := NewRule("/tools/", mustOwners(t, "@org/tools")...)
+	require.NoError(t, err)
+	doc.Rules = append(doc.Rules, rule)
+
+	assert.Equal(t, `# Default owners
+* @org/default
+/tools/ @org/tools
+
+# Services
+
+# Generated below this line
+`, doc.String())
+}
+
+func TestDocumentDeleteLine(t *testing.T) {
+	doc, err := ParseDocument(strings.NewReader("# Docs\n*.md @alice\n*.go @bob\n# Go\n*.md @carol\n"))
+	require.NoError(t, err)
+
+	deleted, err := doc.DeleteLine(2)
+	require.NoError(t, err)
+	assert.Equal(t, mustOwners(t, "@alice"), deleted.Owners)
+	assert.Equal(t, "# Docs\n*.go @bob\n# Go\n*.md @carol\n", doc.String())
+
+	_, err = doc.DeleteLine(5)
+	require.NoError(t, err)
+	assert.Equal(t, "# Docs\n*.go @bob\n# Go\n", doc.String())
+
+	_, err = doc.DeleteLine(2)
+	assert.EqualError(t, err, "no rule on line 2")
+
+	// Section headers and group definitions above a deleted rule are kept
+	gitlab := "* @org/all\n[Docs] @org/docs\n/unused/\n*.md\n"
+	doc, err = ParseDocument(strings.NewReader(gitlab), WithDialect(DialectGitLab))
+	require.NoError(t, err)
+	_, err = doc.DeleteLine(3)
+	require.NoError(t, err)
+	assert.Equal(t, "* @org/all\n[Docs] @org/docs\n*.md\n", doc.String())
+
+	rules, err := ParseFile(strings.NewReader(doc.String()), WithDialect(DialectGitLab))
+	require.NoError(t, err)
+	listing, err := ListOwners(rules, []string{"docs/b.md"}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, mustOwners(t, "@org/all", "@org/docs"), listing[0].Owners)
+
+	bitbucket := "@@@Docs @alice @bob\n/unused/ @@Docs\n*.md @@Docs\n"
+	doc, err = ParseDocument(strings.NewReader(bitbucket), WithDialect(DialectBitbucket))
+	require.NoError(t, err)
+	_, err = doc.DeleteLine(2)
+	require.NoError(t, err)
+	assert.Equal(t, "@@@Docs @alice @bob\n*.md @@Docs\n", doc.String())
+}
+
+func TestDocumentDefaultOwners(t *testing.T) {
+	file := "[Docs] @org/docs\n/docs/\n*.md\n"
+	parse := func() *Document {
+		doc, err := ParseDocument(strings.NewReader(file), WithDialect(DialectGitLab))
+		require.NoError(t, err)
+		return doc
+	}
+
+	// Rules given owners are written with them, instead of the section's default owners
+	doc := parse()
+	require.NoError(t, doc.Rules.Update("/docs/", mustOwners(t, "@org/other")...))
+	assert.Equal(t, "[Docs] @org/docs\n/docs/ @org/other\n*.md\n", doc.String())
+
+	doc = parse()
+	changes := doc.Rules.ReplaceOwners(map[Owner][]Owner{mustOwners(t, "@org/docs")[0]: mustOwners(t, "@org/new")})
+	assert.Len(t, changes, 2)
+	assert.Equal(t, "[Docs] @org/docs\n/docs/ @org/new\n*.md @org/new\n", doc.String())
+
+	rules, err := ParseFile(strings.NewReader(doc.String()), WithDialect(DialectGitLab))
+	require.NoError(t, err)
+	assert.Equal(t, mustOwners(t, "@org/new"), rules[1].Owners)
+
+	// Selections are replaced along with the owners
+	doc, err = ParseDocument(strings.NewReader("* random(1) @alice @bob\n"), WithDialect(DialectBitbucket))
+	require.NoError(t, err)
+	require.NoError(t, doc.Rules.Update("*", mustOwners(t, "@carol")...))
+	assert.Equal(t, "* @carol\n", doc.String())
+}
+
+func TestReplaceOwners(t *testing.T) {
+	rules := mustParse(t, `* @org/default
+/payments/ @org/payments @org/billing
+/legacy/ @departed
+/docs/ @org/docs
+`)
+
+	mapping, err := ParseOwnerMapping(strings.NewReader(`# Payments was split in two
+@org/payments @org/billing @org/checkout
+
+@departed
+`))
+	require.NoError(t, err)
+
+	changes := rules.ReplaceOwners(mapping)
+	assert.Equal(t, []RuleChange{
+		{
+			Line:      2,
+			Pattern:   "/payments/",
+			OldOwners: mustOwners(t, "@org/payments", "@org/billing"),
+			NewOwners: mustOwners(t, "@org/billing", "@org/checkout"),
+		},
+		{
+			Line:      3,
+			Pattern:   "/legacy/",
+			OldOwners: mustOwners(t, "@departed"),
+			NewOwners: []Owner{},
+		},
+	}, changes)
+	assert.False(t, changes[0].Ownerless())
+	assert.True(t, changes[1].Ownerless())
+
+	assert.Equal(t, mustOwners(t, "@org/billing", "@org/checkout"), rules[1].Owners)
+	assert.Empty(t, rules.ReplaceOwners(mapping))
+}
+
+func TestParseOwnerMappingDialect(t *testing.T) {
+	// Groups are owners in Bitbucket files, but not in GitHub ones
+	_, err := ParseOwnerMapping(strings.NewReader("@@Devs @@Backend\n"))
+	assert.EqualError(t, err, "line 1: invalid owner format '@@Devs'")
+
+	mapping, err := ParseOwnerMapping(strings.NewReader("@@Devs @@Backend\n"), WithDialect(DialectBitbucket))
+	require.NoError(t, err)
+	assert.Equal(t, map[Owner][]Owner{
+		{Value: "Devs", Type: GroupOwner}: {{Value: "Backend", Type: GroupOwner}},
+	}, mapping)
+
+	owner, err := ParseDialectOwner("@org/backend/leads", DialectGitLab)
+	require.NoError(t, err)
+	assert.Equal(t, Owner{Value: "org/backend/leads", Type: TeamOwner}, owner)
+}
+
+func TestParseOwnerMappingErrors(t *testing.T) {
+	_, err := ParseOwnerMapping(strings.NewReader("@org/a @org/b\nteam\n"))
+	assert.EqualError(t, err, "line 2: invalid owner format 'team'")
+
+	_, err = ParseOwnerMapping(strings.NewReader("@org/a @org/b\n@org/a @org/c\n"))
+	assert.EqualError(t, err, "line 2: @org/a is mapped more than once")
+}
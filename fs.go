@@ -0,0 +1,93 @@
+package codeowners
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// standardLocations are the paths, relative to the repository root, where CODEOWNERS files are
+// looked for, in order of precedence.
+var standardLocations = []string{"CODEOWNERS", ".github/CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"}
+
+// LoadFileFS loads and parses a CODEOWNERS file at the path specified within fsys.
+func LoadFileFS(fsys fs.FS, name string, opts ...ParseOption) ([]Rule, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseFile(f, opts...)
+}
+
+// LoadFileFromStandardLocationFS loads and parses the CODEOWNERS file at the first of the standard
+// locations (./, .github/, .gitlab/, docs/) that exists in fsys.
+func LoadFileFromStandardLocationFS(fsys fs.FS, opts ...ParseOption) ([]Rule, error) {
+	name := FindFileFS(fsys)
+	if name == "" {
+		return nil, &fs.PathError{Op: "open", Path: "CODEOWNERS", Err: fs.ErrNotExist}
+	}
+	return LoadFileFS(fsys, name, opts...)
+}
+
+// FindFileFS returns the first of the standard CODEOWNERS locations at which a regular file exists
+// in fsys, or an empty string if there is none.
+func FindFileFS(fsys fs.FS) string {
+	for _, name := range standardLocations {
+		if info, err := fs.Stat(fsys, name); err == nil && !info.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
+
+// ListFilesFS lists every file below the given roots in fsys, sorted and without duplicates. Roots
+// that are files are listed as-is, and .git directories are skipped. With no roots, the whole of
+// fsys is listed.
+func ListFilesFS(fsys fs.FS, roots ...string) ([]string, error) {
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	files := make([]string, 0)
+	for _, root := range roots {
+		err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			files = append(files, name)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	unique := files[:0]
+	for i, file := range files {
+		if i == 0 || file != files[i-1] {
+			unique = append(unique, file)
+		}
+	}
+	return unique, nil
+}
+
+// FSStat returns an FsStat that looks paths up in fsys, for use with ConsolidateTree. Leading and
+// trailing slashes, which are meaningful in patterns but not in fs.FS names, are ignored.
+func FSStat(fsys fs.FS) FsStat {
+	return func(name string) (fs.FileInfo, error) {
+		name = strings.Trim(name, "/")
+		if name == "" {
+			name = "."
+		}
+		return fs.Stat(fsys, path.Clean(name))
+	}
+}
@@ -0,0 +1,79 @@
+package codeowners
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		".github/CODEOWNERS":   {Data: []byte("* @org/everyone\n/docs/ @org/docs\n")},
+		"docs/CODEOWNERS":      {Data: []byte("* @org/ignored\n")},
+		"docs/index.md":        {Data: []byte("# Docs\n")},
+		"src/main.go":          {Data: []byte("package main\n")},
+		"src/lib/lib.go":       {Data: []byte("package lib\n")},
+		".git/HEAD":            {Data: []byte("ref: refs/heads/main\n")},
+		"vendor/dep/.git/HEAD": {Data: []byte("ref: refs/heads/main\n")},
+		"vendor/dep/dep.go":    {Data: []byte("package dep\n")},
+	}
+}
+
+func TestFindFileFS(t *testing.T) {
+	assert.Equal(t, ".github/CODEOWNERS", FindFileFS(testFS()))
+
+	fsys := testFS()
+	delete(fsys, ".github/CODEOWNERS")
+	assert.Equal(t, "docs/CODEOWNERS", FindFileFS(fsys))
+
+	// Directories named CODEOWNERS don't count
+	assert.Equal(t, "", FindFileFS(fstest.MapFS{"CODEOWNERS/file": {}}))
+}
+
+func TestLoadFileFromStandardLocationFS(t *testing.T) {
+	rules, err := LoadFileFromStandardLocationFS(testFS())
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "/docs/", rules[1].RawPattern())
+
+	_, err = LoadFileFromStandardLocationFS(fstest.MapFS{})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestListFilesFS(t *testing.T) {
+	files, err := ListFilesFS(testFS())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		".github/CODEOWNERS",
+		"docs/CODEOWNERS",
+		"docs/index.md",
+		"src/lib/lib.go",
+		"src/main.go",
+		"vendor/dep/dep.go",
+	}, files)
+
+	files, err = ListFilesFS(testFS(), "src", "docs/index.md", "src/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs/index.md", "src/lib/lib.go", "src/main.go"}, files)
+
+	_, err = ListFilesFS(testFS(), "missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestFSStat(t *testing.T) {
+	stat := FSStat(testFS())
+
+	info, err := stat("/src/")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	info, err = stat("src/main.go")
+	require.NoError(t, err)
+	assert.False(t, info.IsDir())
+
+	_, err = stat("src/missing.go")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
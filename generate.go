@@ -0,0 +1,352 @@
+package codeowners
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// generateCandidates is the number of owner lists, the most common beneath a directory, considered
+// for its rule.
+const generateCandidates = 3
+
+// unrepresentable is the cost of a file whose path can't be written as a pattern, large enough
+// that any alternative is preferred.
+const unrepresentable = 1 << 20
+
+// GenerateRules returns a small ruleset giving each file exactly the owners listed for it, with
+// owners in the same order, under last-match-wins semantics. Unowned files have no owners or
+// belong to the Unowned group. Files not listed may have any owners.
+//
+// Rules are chosen per directory: a directory gets a rule for one of the owner lists most common
+// beneath it when that saves rules, and files differing from their directory get their own rule.
+// Extensions whose files all share the same owners get a single glob rule, such as "*.proto", at
+// the end of the file when that saves rules too. The result is verified before it is returned.
+func GenerateRules(files Owners) (Ruleset, error) {
+	g := newGenerator(files)
+
+	// Try extension globs, most common first, keeping those that save at least one rule
+	best := g.cost(g.root, "")
+	for _, ext := range g.extensionCandidates() {
+		g.setDontCare(ext, true)
+		if cost := g.cost(g.root, "") + 1; cost < best {
+			best = cost
+			g.globs = append(g.globs, ext)
+			continue
+		}
+		g.setDontCare(ext, false)
+	}
+
+	rules := make(Ruleset, 0, best)
+	if err := g.emit(g.root, "", &rules); err != nil {
+		return nil, err
+	}
+	for _, ext := range g.globs {
+		rule, err := NewRule("*"+ext, g.labels[g.extLabel[ext]]...)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, verifyRules(rules, files)
+}
+
+// generateNode is a file or directory in the tree considered by GenerateRules.
+type generateNode struct {
+	path     string
+	isDir    bool
+	children []*generateNode
+	// label identifies the owners of a file
+	label string
+	// dontCare is set for files owned by an extension glob, whose owners don't matter otherwise
+	dontCare bool
+	// ruleCost is the cost of a rule for the node: 1, or unrepresentable
+	ruleCost int
+}
+
+type generateChoice struct {
+	rule  bool
+	label string
+}
+
+type generateKey struct {
+	node      *generateNode
+	inherited string
+}
+
+type generator struct {
+	root   *generateNode
+	files  []*generateNode
+	labels map[string][]Owner
+	// extLabel is the owners shared by all files with an extension, if they share them and no
+	// directory has the extension
+	extLabel map[string]string
+	extCount map[string]int
+	globs    []string
+
+	memo       map[generateKey]int
+	choices    map[generateKey]generateChoice
+	candidates map[*generateNode][]string
+}
+
+func newGenerator(files Owners) *generator {
+	g := &generator{
+		root:     &generateNode{isDir: true, ruleCost: 1},
+		labels:   map[string][]Owner{"": {}},
+		extLabel: make(map[string]string),
+		extCount: make(map[string]int),
+	}
+
+	// Extensions are mixed when their files have different owners, or when a directory has the
+	// extension too, as an extension glob would also match the files beneath it
+	mixed := make(map[string]bool)
+	dirs := map[string]*generateNode{"": g.root}
+	var dir func(p string) *generateNode
+	dir = func(p string) *generateNode {
+		if node, ok := dirs[p]; ok {
+			return node
+		}
+		node := newGenerateNode(p, true)
+		if ext := fileExtension(p); ext != "" {
+			mixed[ext] = true
+		}
+		parent := dir(parentDir(p))
+		parent.children = append(parent.children, node)
+		dirs[p] = node
+		return node
+	}
+
+	for _, file := range files {
+		label := ownersLabel(file.Owners)
+		if _, ok := g.labels[label]; !ok && label != "" {
+			g.labels[label] = file.Owners
+		}
+
+		node := newGenerateNode(file.Path, false)
+		node.label = label
+		parent := dir(parentDir(file.Path))
+		parent.children = append(parent.children, node)
+		g.files = append(g.files, node)
+
+		if ext := fileExtension(file.Path); ext != "" {
+			if other, ok := g.extLabel[ext]; ok && other != label {
+				mixed[ext] = true
+			}
+			g.extLabel[ext] = label
+			g.extCount[ext]++
+		}
+	}
+	for ext := range mixed {
+		delete(g.extLabel, ext)
+	}
+
+	var sortTree func(n *generateNode)
+	sortTree = func(n *generateNode) {
+		sort.Slice(n.children, func(i, j int) bool { return n.children[i].path < n.children[j].path })
+		for _, child := range n.children {
+			sortTree(child)
+		}
+	}
+	sortTree(g.root)
+
+	return g
+}
+
+// newGenerateNode returns a node for a file or directory, checking that its path can be written
+// as a pattern.
+func newGenerateNode(p string, isDir bool) *generateNode {
+	node := &generateNode{path: p, isDir: isDir, ruleCost: 1}
+	if _, err := NewRule(suggestPattern(p, isDir)); err != nil {
+		node.ruleCost = unrepresentable
+	}
+	return node
+}
+
+// extensionCandidates returns the extensions whose files all have the same owners, and which no
+// directory has, most common first.
+func (g *generator) extensionCandidates() []string {
+	exts := make([]string, 0, len(g.extLabel))
+	for ext := range g.extLabel {
+		if g.extCount[ext] > 1 {
+			if _, err := NewRule("*" + ext); err == nil {
+				exts = append(exts, ext)
+			}
+		}
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if g.extCount[exts[i]] != g.extCount[exts[j]] {
+			return g.extCount[exts[i]] > g.extCount[exts[j]]
+		}
+		return exts[i] < exts[j]
+	})
+	return exts
+}
+
+func (g *generator) setDontCare(ext string, dontCare bool) {
+	for _, file := range g.files {
+		if fileExtension(file.path) == ext {
+			file.dontCare = dontCare
+		}
+	}
+	g.memo, g.choices, g.candidates = nil, nil, nil
+}
+
+// cost returns the number of rules needed beneath the node, given the owners it inherits from the
+// rules of its ancestors, recording the best choice for emit.
+func (g *generator) cost(n *generateNode, inherited string) int {
+	if g.memo == nil {
+		g.memo = make(map[generateKey]int)
+		g.choices = make(map[generateKey]generateChoice)
+		g.candidates = make(map[*generateNode][]string)
+	}
+
+	key := generateKey{n, inherited}
+	if cost, ok := g.memo[key]; ok {
+		return cost
+	}
+
+	var cost int
+	choice := generateChoice{label: inherited}
+
+	if !n.isDir {
+		if !n.dontCare && n.label != inherited {
+			choice = generateChoice{rule: true, label: n.label}
+			cost = n.ruleCost
+		}
+	} else {
+		cost = g.childrenCost(n, inherited)
+
+		for _, label := range g.commonLabels(n) {
+			if label == inherited {
+				continue
+			}
+			// On a tie, prefer a rule that owns the whole directory, which also covers files added
+			// to it later
+			children := g.childrenCost(n, label)
+			if c := n.ruleCost + children; c < cost || (c == cost && children == 0) {
+				cost = c
+				choice = generateChoice{rule: true, label: label}
+			}
+		}
+	}
+
+	g.memo[key] = cost
+	g.choices[key] = choice
+	return cost
+}
+
+func (g *generator) childrenCost(n *generateNode, inherited string) int {
+	cost := 0
+	for _, child := range n.children {
+		cost += g.cost(child, inherited)
+	}
+	return cost
+}
+
+// commonLabels returns the owner lists most common among the files beneath the node.
+func (g *generator) commonLabels(n *generateNode) []string {
+	if labels, ok := g.candidates[n]; ok {
+		return labels
+	}
+
+	counts := make(map[string]int)
+	var count func(n *generateNode)
+	count = func(n *generateNode) {
+		if !n.isDir {
+			if !n.dontCare {
+				counts[n.label]++
+			}
+			return
+		}
+		for _, child := range n.children {
+			count(child)
+		}
+	}
+	count(n)
+
+	labels := make([]string, 0, len(counts))
+	for label := range counts {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if counts[labels[i]] != counts[labels[j]] {
+			return counts[labels[i]] > counts[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+	if len(labels) > generateCandidates {
+		labels = labels[:generateCandidates]
+	}
+	g.candidates[n] = labels
+	return labels
+}
+
+// emit appends the rules chosen for the node and its descendants, parents before children.
+func (g *generator) emit(n *generateNode, inherited string, rules *Ruleset) error {
+	g.cost(n, inherited)
+	choice := g.choices[generateKey{n, inherited}]
+
+	if choice.rule {
+		pattern := "*"
+		if n != g.root {
+			pattern = suggestPattern(n.path, n.isDir)
+		}
+		rule, err := NewRule(pattern, g.labels[choice.label]...)
+		if err != nil {
+			return fmt.Errorf("cannot write a rule for %s: %v", n.path, err)
+		}
+		*rules = append(*rules, rule)
+	}
+
+	for _, child := range n.children {
+		if err := g.emit(child, choice.label, rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyRules checks that the rules give every file exactly its listed owners.
+func verifyRules(rules Ruleset, files Owners) error {
+	matcher := NewMatcher(rules)
+	for _, file := range files {
+		rule, err := matcher.Match(file.Path)
+		if err != nil {
+			return err
+		}
+
+		var actual []Owner
+		if rule != nil {
+			actual = rule.Owners
+		}
+		if ownersLabel(actual) != ownersLabel(file.Owners) {
+			return fmt.Errorf("generated rules give %s the owners %v instead of %v", file.Path, actual, file.Owners)
+		}
+	}
+	return nil
+}
+
+// ownersLabel identifies a list of owners, with the same label for all ways of being unowned.
+func ownersLabel(owners []Owner) string {
+	if len(owners) == 1 && owners[0] == Unowned {
+		return ""
+	}
+
+	names := make([]string, len(owners))
+	for i, owner := range owners {
+		names[i] = owner.String()
+	}
+	return strings.Join(names, " ")
+}
+
+// fileExtension returns the extension of the file, ignoring dotfiles without one such as .gitignore.
+func fileExtension(p string) string {
+	base := path.Base(p)
+	ext := path.Ext(base)
+	if ext == base {
+		return ""
+	}
+	return ext
+}
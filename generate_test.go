@@ -0,0 +1,94 @@
+package codeowners
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRules(t *testing.T) {
+	owners := func(o ...string) []Owner { return mustOwners(t, o...) }
+	files := Owners{
+		{Path: "README.md", Owners: owners("@org/docs")},
+		{Path: "go.mod", Owners: owners("@org/core")},
+		{Path: "api/v1/users.proto", Owners: owners("@org/api")},
+		{Path: "api/v1/users.go", Owners: owners("@org/core")},
+		{Path: "api/v1/groups.go", Owners: owners("@org/core")},
+		{Path: "services/payments/a.go", Owners: owners("@org/payments", "@org/core")},
+		{Path: "services/payments/b.go", Owners: owners("@org/payments", "@org/core")},
+		{Path: "services/payments/c.go", Owners: owners("@org/payments", "@org/core")},
+		{Path: "services/payments/legacy.go", Owners: []Owner{Unowned}},
+		{Path: "services/payments/doc.md", Owners: owners("@org/docs")},
+		{Path: "services/search/index.go", Owners: owners("@org/search")},
+		{Path: "services/search/query.go", Owners: owners("@org/search")},
+		{Path: "services/search/schema.proto", Owners: owners("@org/api")},
+		{Path: "tools/gen.go", Owners: owners("@org/core")},
+		{Path: "vendor/lib/lib.go", Owners: []Owner{}},
+		{Path: "vendor/lib/lib_test.go", Owners: []Owner{}},
+	}
+
+	rules, err := GenerateRules(files)
+	require.NoError(t, err)
+
+	patterns := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		patterns = append(patterns, rule.String())
+	}
+	assert.Equal(t, []string{
+		"* @org/core",
+		"/services/payments/ @org/payments @org/core",
+		"/services/payments/legacy.go",
+		"/services/search/ @org/search",
+		"/vendor/",
+		"*.md @org/docs",
+		"*.proto @org/api",
+	}, patterns)
+
+	actual, err := ListOwners(rules, []string{"services/payments/legacy.go", "api/v1/users.proto"}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, Owners{
+		{Path: "services/payments/legacy.go", Owners: []Owner{Unowned}},
+		{Path: "api/v1/users.proto", Owners: owners("@org/api")},
+	}, actual)
+}
+
+func TestGenerateRulesExtensionDirectories(t *testing.T) {
+	// *.d would also match the conf.d directory, so it can't own a.d and b.d
+	files := Owners{
+		{Path: "README.md", Owners: mustOwners(t, "@w")},
+		{Path: "Makefile", Owners: mustOwners(t, "@w")},
+		{Path: "src/a.d", Owners: mustOwners(t, "@x")},
+		{Path: "src/main.c", Owners: mustOwners(t, "@w")},
+		{Path: "lib/b.d", Owners: mustOwners(t, "@x")},
+		{Path: "lib/lib.c", Owners: mustOwners(t, "@w")},
+		{Path: "conf.d/x.conf", Owners: mustOwners(t, "@y")},
+		{Path: "conf.d/z.conf", Owners: mustOwners(t, "@z")},
+	}
+
+	rules, err := GenerateRules(files)
+	require.NoError(t, err)
+	for _, rule := range rules {
+		assert.NotEqual(t, "*.d", rule.RawPattern())
+	}
+}
+
+func TestGenerateRulesReproducesRuleset(t *testing.T) {
+	rules, files := largeRuleset(t, 300, 5000)
+
+	listing, err := ListOwners(rules, files, nil, false)
+	require.NoError(t, err)
+
+	generated, err := GenerateRules(listing)
+	require.NoError(t, err)
+	assert.NoError(t, verifyRules(generated, listing))
+}
+
+func TestGenerateRulesUnrepresentable(t *testing.T) {
+	_, err := GenerateRules(Owners{
+		{Path: "a.go", Owners: mustOwners(t, "@org/a")},
+		{Path: "weird#1.go", Owners: mustOwners(t, "@org/b")},
+		{Path: "weird#2.go", Owners: mustOwners(t, "@org/c")},
+	})
+	assert.EqualError(t, err, `cannot write a rule for weird#2.go: invalid pattern "/weird#2.go"`)
+}
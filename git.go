@@ -0,0 +1,590 @@
+package codeowners
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Repository reads file lists and file contents from a git repository. All paths are relative to
+// the root of the repository's working tree, using forward slashes.
+type Repository interface {
+	// Root returns the path to the root of the working tree.
+	Root() string
+	// LsFiles lists every file in the tree at ref. If ref is empty, the files in the index are
+	// listed instead.
+	LsFiles(ref string) ([]string, error)
+	// ReadFile returns the contents of a file in the tree at ref. If ref is empty, the file is read
+	// from the working tree.
+	ReadFile(ref, path string) ([]byte, error)
+	// LsUntrackedFiles lists files in the working tree that are neither tracked nor ignored.
+	LsUntrackedFiles() ([]string, error)
+	// ResolveRef returns the full object name that ref refers to.
+	ResolveRef(ref string) (string, error)
+}
+
+// OpenRepository opens the git repository containing dir. Trees and blobs are read directly from
+// the .git directory, falling back to the git binary for anything the native reader doesn't
+// support (e.g. revision expressions like "main@{yesterday}").
+func OpenRepository(dir string) (Repository, error) {
+	native, err := OpenNativeRepository(dir)
+	if err != nil {
+		return NewExecRepository(dir)
+	}
+	return &FallbackRepository{Primary: native, Fallback: &ExecRepository{root: native.Root()}}, nil
+}
+
+// FallbackRepository reads from Primary, retrying with Fallback when Primary fails.
+type FallbackRepository struct {
+	Primary  Repository
+	Fallback Repository
+}
+
+// Root returns the path to the root of the working tree.
+func (f *FallbackRepository) Root() string {
+	return f.Primary.Root()
+}
+
+// LsFiles lists every file in the tree at ref.
+func (f *FallbackRepository) LsFiles(ref string) ([]string, error) {
+	files, err := f.Primary.LsFiles(ref)
+	if err == nil {
+		return files, nil
+	}
+
+	files, fallbackErr := f.Fallback.LsFiles(ref)
+	if fallbackErr != nil {
+		return nil, pickError(err, fallbackErr)
+	}
+	return files, nil
+}
+
+// ReadFile returns the contents of a file in the tree at ref.
+func (f *FallbackRepository) ReadFile(ref, path string) ([]byte, error) {
+	data, err := f.Primary.ReadFile(ref, path)
+	if err == nil {
+		return data, nil
+	}
+
+	data, fallbackErr := f.Fallback.ReadFile(ref, path)
+	if fallbackErr != nil {
+		return nil, pickError(err, fallbackErr)
+	}
+	return data, nil
+}
+
+// LsUntrackedFiles lists files in the working tree that are neither tracked nor ignored.
+func (f *FallbackRepository) LsUntrackedFiles() ([]string, error) {
+	files, err := f.Primary.LsUntrackedFiles()
+	if err == nil {
+		return files, nil
+	}
+
+	files, fallbackErr := f.Fallback.LsUntrackedFiles()
+	if fallbackErr != nil {
+		return nil, pickError(err, fallbackErr)
+	}
+	return files, nil
+}
+
+// ResolveRef returns the full object name that ref refers to.
+func (f *FallbackRepository) ResolveRef(ref string) (string, error) {
+	name, err := f.Primary.ResolveRef(ref)
+	if err == nil {
+		return name, nil
+	}
+
+	name, fallbackErr := f.Fallback.ResolveRef(ref)
+	if fallbackErr != nil {
+		return "", pickError(err, fallbackErr)
+	}
+	return name, nil
+}
+
+// pickError chooses the more useful of two errors: a missing git binary says nothing about why the
+// native reader failed.
+func pickError(primary, fallback error) error {
+	var execErr *exec.Error
+	if errors.As(fallback, &execErr) {
+		return primary
+	}
+	return fallback
+}
+
+// ExecRepository reads from a git repository by running the git binary.
+type ExecRepository struct {
+	root string
+}
+
+// NewExecRepository returns an ExecRepository for the repository containing dir.
+func NewExecRepository(dir string) (*ExecRepository, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return &ExecRepository{root: strings.TrimSpace(string(output))}, nil
+}
+
+// Root returns the path to the root of the working tree.
+func (e *ExecRepository) Root() string {
+	return e.root
+}
+
+// LsFiles lists every file in the tree at ref.
+func (e *ExecRepository) LsFiles(ref string) ([]string, error) {
+	var cmd *exec.Cmd
+	if ref == "" {
+		cmd = exec.Command("git", "ls-files", "-z")
+	} else {
+		cmd = exec.Command("git", "ls-tree", "-r", "-z", "--full-tree", "--name-only", ref)
+	}
+	return e.lsFiles(cmd)
+}
+
+// LsUntrackedFiles lists files in the working tree that are neither tracked nor ignored.
+func (e *ExecRepository) LsUntrackedFiles() ([]string, error) {
+	return e.lsFiles(exec.Command("git", "ls-files", "-z", "--others", "--exclude-standard"))
+}
+
+func (e *ExecRepository) lsFiles(cmd *exec.Cmd) ([]string, error) {
+	cmd.Dir = e.root
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	files := strings.Split(string(output), "\x00")
+	return files[:len(files)-1], nil
+}
+
+// ReadFile returns the contents of a file in the tree at ref.
+func (e *ExecRepository) ReadFile(ref, path string) ([]byte, error) {
+	if ref == "" {
+		return os.ReadFile(filepath.Join(e.root, filepath.FromSlash(path)))
+	}
+
+	cmd := exec.Command("git", "show", fmt.Sprintf("%s:%s", ref, path))
+	cmd.Dir = e.root
+	return cmd.Output()
+}
+
+// ResolveRef returns the full object name that ref refers to.
+func (e *ExecRepository) ResolveRef(ref string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", "--end-of-options", ref)
+	cmd.Dir = e.root
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", fmt.Errorf("unknown revision %q", ref)
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// NativeRepository reads from a git repository's object database directly, without the git binary.
+// It understands loose objects, packfiles, alternates, linked worktrees and index versions 2 to 4.
+type NativeRepository struct {
+	root      string
+	gitDir    string
+	commonDir string
+	objects   *objectStore
+}
+
+// errUnsupported is returned for repository features the native reader doesn't implement.
+var errUnsupported = errors.New("not supported by native git reader")
+
+// OpenNativeRepository opens the git repository containing dir.
+func OpenNativeRepository(dir string) (*NativeRepository, error) {
+	root, gitDir, err := findGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	if config, err := os.ReadFile(filepath.Join(commonDir, "config")); err == nil {
+		if bytes.Contains(bytes.ToLower(config), []byte("objectformat = sha256")) {
+			return nil, fmt.Errorf("sha256 repositories are %w", errUnsupported)
+		}
+	}
+
+	return &NativeRepository{
+		root:      root,
+		gitDir:    gitDir,
+		commonDir: commonDir,
+		objects:   newObjectStore(filepath.Join(commonDir, "objects")),
+	}, nil
+}
+
+// findGitDir walks up from dir looking for a .git directory, or a .git file pointing to one, as
+// created for linked worktrees and submodules.
+func findGitDir(dir string) (root, gitDir string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotGit)
+		switch {
+		case err == nil && info.IsDir():
+			return dir, dotGit, nil
+
+		case err == nil:
+			data, err := os.ReadFile(dotGit)
+			if err != nil {
+				return "", "", err
+			}
+			line := strings.TrimSpace(string(data))
+			if !strings.HasPrefix(line, "gitdir:") {
+				return "", "", fmt.Errorf("%s: invalid gitfile format", dotGit)
+			}
+			gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+			return dir, gitDir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fmt.Errorf("not a git repository (or any of the parent directories)")
+		}
+		dir = parent
+	}
+}
+
+// Root returns the path to the root of the working tree.
+func (n *NativeRepository) Root() string {
+	return n.root
+}
+
+// LsFiles lists every file in the tree at ref.
+func (n *NativeRepository) LsFiles(ref string) ([]string, error) {
+	if ref == "" {
+		return readIndex(filepath.Join(n.gitDir, "index"))
+	}
+
+	tree, err := n.resolveTree(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0)
+	err = n.walkTree(tree, "", func(path string, entry treeEntry) {
+		files = append(files, path)
+	})
+	return files, err
+}
+
+// LsUntrackedFiles is not supported natively, as it requires evaluating .gitignore files.
+func (n *NativeRepository) LsUntrackedFiles() ([]string, error) {
+	return nil, fmt.Errorf("listing untracked files is %w", errUnsupported)
+}
+
+// ReadFile returns the contents of a file in the tree at ref.
+func (n *NativeRepository) ReadFile(ref, path string) ([]byte, error) {
+	if ref == "" {
+		return os.ReadFile(filepath.Join(n.root, filepath.FromSlash(path)))
+	}
+
+	h, err := n.resolveTree(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	for i, seg := range segs {
+		entries, err := n.readTree(h)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, entry := range entries {
+			if entry.name != seg {
+				continue
+			}
+			if isDir := entry.mode == modeTree; isDir != (i < len(segs)-1) {
+				break
+			}
+			h, found = entry.hash, true
+			break
+		}
+		if !found {
+			return nil, &fs.PathError{Op: "read", Path: ref + ":" + path, Err: fs.ErrNotExist}
+		}
+	}
+
+	typ, data, err := n.objects.read(h)
+	if err != nil {
+		return nil, err
+	}
+	if typ != objBlob {
+		return nil, fmt.Errorf("%s:%s is not a file", ref, path)
+	}
+	return data, nil
+}
+
+// ResolveRef returns the full object name that ref refers to.
+func (n *NativeRepository) ResolveRef(ref string) (string, error) {
+	h, err := n.resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	return h.String(), nil
+}
+
+// resolveTree resolves a revision to the hash of its root tree.
+func (n *NativeRepository) resolveTree(rev string) (hash, error) {
+	h, err := n.resolve(rev)
+	if err != nil {
+		return hash{}, err
+	}
+	return n.peel(h, objTree)
+}
+
+// resolve turns a revision into an object hash. It supports ref names, full and abbreviated object
+// names, and the ~<n>, ^<n> and ^{<type>} suffixes.
+func (n *NativeRepository) resolve(rev string) (hash, error) {
+	base := rev
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		base = rev[:i]
+	}
+	if base == "" || base == "@" {
+		base = "HEAD"
+	}
+	if strings.ContainsAny(base, ":@{}") {
+		return hash{}, fmt.Errorf("revision %q is %w", rev, errUnsupported)
+	}
+
+	h, err := n.resolveName(base)
+	if err != nil {
+		return hash{}, err
+	}
+
+	for ops := rev[len(base):]; ops != ""; {
+		op := ops[0]
+		ops = ops[1:]
+		if op != '~' && op != '^' {
+			return hash{}, fmt.Errorf("revision %q is %w", rev, errUnsupported)
+		}
+
+		// ^{type} peels to an object of the given type
+		if op == '^' && strings.HasPrefix(ops, "{") {
+			end := strings.IndexByte(ops, '}')
+			if end < 0 {
+				return hash{}, fmt.Errorf("invalid revision %q", rev)
+			}
+			typ := objTypeNames[ops[1:end]]
+			ops = ops[end+1:]
+			if typ == 0 {
+				continue // ^{} peels tags, which resolving the commit below does anyway
+			}
+			if h, err = n.peel(h, typ); err != nil {
+				return hash{}, err
+			}
+			continue
+		}
+
+		digits := len(ops) - len(strings.TrimLeft(ops, "0123456789"))
+		count := 1
+		if digits > 0 {
+			count, _ = strconv.Atoi(ops[:digits])
+			ops = ops[digits:]
+		}
+
+		if op == '^' {
+			// ^<n> selects the nth parent; ^0 is the commit itself
+			if h, err = n.parent(h, count); err != nil {
+				return hash{}, fmt.Errorf("%s: %w", rev, err)
+			}
+			continue
+		}
+
+		// ~<n> follows first parents n times
+		for i := 0; i < count; i++ {
+			if h, err = n.parent(h, 1); err != nil {
+				return hash{}, fmt.Errorf("%s: %w", rev, err)
+			}
+		}
+	}
+
+	return h, nil
+}
+
+// parent returns the nth parent of a commit, counting from 1. The 0th parent is the commit itself.
+func (n *NativeRepository) parent(h hash, nth int) (hash, error) {
+	commit, err := n.peel(h, objCommit)
+	if err != nil {
+		return hash{}, err
+	}
+	if nth == 0 {
+		return commit, nil
+	}
+
+	_, data, err := n.objects.read(commit)
+	if err != nil {
+		return hash{}, err
+	}
+
+	parents := headerValues(data, "parent")
+	if nth > len(parents) {
+		return hash{}, fmt.Errorf("commit %s has no parent %d", commit, nth)
+	}
+	return parseHash(parents[nth-1])
+}
+
+// peel follows tags and commits until it reaches an object of the wanted type.
+func (n *NativeRepository) peel(h hash, want objType) (hash, error) {
+	for {
+		typ, data, err := n.objects.read(h)
+		if err != nil {
+			return hash{}, err
+		}
+
+		var next []string
+		switch {
+		case typ == want:
+			return h, nil
+		case typ == objTag:
+			next = headerValues(data, "object")
+		case typ == objCommit && want == objTree:
+			next = headerValues(data, "tree")
+		}
+
+		if len(next) == 0 {
+			return hash{}, fmt.Errorf("object %s is a %s, not a %s", h, typ, want)
+		}
+		if h, err = parseHash(next[0]); err != nil {
+			return hash{}, err
+		}
+	}
+}
+
+// resolveName resolves a ref name or object name to a hash, following the same precedence as
+// git rev-parse.
+func (n *NativeRepository) resolveName(name string) (hash, error) {
+	if len(name) == 2*hashSize {
+		if h, err := parseHash(name); err == nil {
+			return h, nil
+		}
+	}
+
+	for _, format := range []string{"%s", "refs/%s", "refs/tags/%s", "refs/heads/%s", "refs/remotes/%s", "refs/remotes/%s/HEAD"} {
+		h, err := n.readRef(fmt.Sprintf(format, name), 0)
+		if err == nil {
+			return h, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return hash{}, err
+		}
+	}
+
+	if len(name) >= 4 && strings.Trim(name, "0123456789abcdef") == "" {
+		return n.objects.expand(name)
+	}
+
+	return hash{}, fmt.Errorf("unknown revision %q", name)
+}
+
+// readRef reads a loose or packed ref, following symbolic refs.
+func (n *NativeRepository) readRef(name string, depth int) (hash, error) {
+	if depth > 5 {
+		return hash{}, fmt.Errorf("symbolic ref %s is too deep", name)
+	}
+
+	// Pseudo-refs such as HEAD live in the worktree's own git dir; everything else is shared.
+	dir := n.commonDir
+	if !strings.Contains(name, "/") || strings.HasPrefix(name, "refs/worktree/") || strings.HasPrefix(name, "refs/bisect/") {
+		dir = n.gitDir
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err == nil {
+		content := strings.TrimSpace(string(data))
+		if target := strings.TrimPrefix(content, "ref:"); target != content {
+			return n.readRef(strings.TrimSpace(target), depth+1)
+		}
+		return parseHash(content)
+	}
+
+	if !strings.HasPrefix(name, "refs/") {
+		return hash{}, &fs.PathError{Op: "resolve", Path: name, Err: fs.ErrNotExist}
+	}
+
+	packed, err := os.ReadFile(filepath.Join(n.commonDir, "packed-refs"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return hash{}, err
+	}
+	for _, line := range strings.Split(string(packed), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == name {
+			return parseHash(fields[0])
+		}
+	}
+
+	return hash{}, &fs.PathError{Op: "resolve", Path: name, Err: fs.ErrNotExist}
+}
+
+// walkTree calls fn for every non-tree entry below the tree, in the same order as git ls-tree -r.
+func (n *NativeRepository) walkTree(h hash, prefix string, fn func(string, treeEntry)) error {
+	entries, err := n.readTree(h)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := prefix + entry.name
+		if entry.mode == modeTree {
+			if err := n.walkTree(entry.hash, path+"/", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		fn(path, entry)
+	}
+	return nil
+}
+
+func (n *NativeRepository) readTree(h hash) ([]treeEntry, error) {
+	typ, data, err := n.objects.read(h)
+	if err != nil {
+		return nil, err
+	}
+	if typ != objTree {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", h, typ)
+	}
+	return parseTree(data)
+}
+
+// headerValues returns the values of every header line with the given key in a commit or tag.
+func headerValues(data []byte, key string) []string {
+	var values []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break // the message follows the first blank line
+		}
+		if strings.HasPrefix(line, key+" ") {
+			values = append(values, line[len(key)+1:])
+		}
+	}
+	return values
+}
@@ -0,0 +1,175 @@
+package codeowners
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitFixture creates a repository with a short history using the git binary, so the native reader
+// can be checked against it.
+func gitFixture(t *testing.T) (string, func(args ...string) string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false", "-c", "tag.gpgsign=false"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %s: %s", strings.Join(args, " "), out)
+		return strings.TrimSpace(string(out))
+	}
+	write := func(path, content string) {
+		full := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0o644))
+	}
+
+	git("init", "-q", "-b", "main")
+	write(".github/CODEOWNERS", "* @org/everyone\n")
+	write("src/app/main.go", strings.Repeat("package main\n", 100))
+	write("src/app-util/util.go", "package util\n")
+	write("src/app.go", "package src\n")
+	write("docs/index.md", "# Docs\n")
+	require.NoError(t, os.Symlink("docs/index.md", filepath.Join(dir, "README.md")))
+	git("add", "-A")
+	git("commit", "-q", "-m", "first")
+	git("tag", "-a", "v1", "-m", "version 1")
+
+	write(".github/CODEOWNERS", "* @org/everyone\n/src/ @org/src\n")
+	write("src/app/main.go", strings.Repeat("package main\n", 100)+"func main() {}\n")
+	write("src/app/ソース.go", "package main\n")
+	git("rm", "-q", "docs/index.md")
+	git("add", "-A")
+	git("commit", "-q", "-m", "second")
+	git("checkout", "-q", "-b", "feature")
+	write("feature.txt", "new\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "third")
+	git("checkout", "-q", "main")
+	write("staged.txt", "staged\n")
+	git("add", "staged.txt")
+
+	return dir, git
+}
+
+func assertSameRepository(t *testing.T, dir string, refs []string) {
+	native, err := OpenNativeRepository(dir)
+	require.NoError(t, err)
+	execRepo, err := NewExecRepository(dir)
+	require.NoError(t, err)
+
+	for _, ref := range refs {
+		t.Run(fmt.Sprintf("ref %q", ref), func(t *testing.T) {
+			expected, err := execRepo.LsFiles(ref)
+			require.NoError(t, err)
+			actual, err := native.LsFiles(ref)
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual)
+
+			if ref == "" {
+				return
+			}
+
+			expectedName, err := execRepo.ResolveRef(ref)
+			require.NoError(t, err)
+			actualName, err := native.ResolveRef(ref)
+			require.NoError(t, err)
+			assert.Equal(t, expectedName, actualName)
+
+			for _, file := range expected {
+				expected, err := execRepo.ReadFile(ref, file)
+				require.NoError(t, err)
+				actual, err := native.ReadFile(ref, file)
+				require.NoError(t, err)
+				assert.Equal(t, expected, actual, "%s:%s", ref, file)
+			}
+		})
+	}
+}
+
+func TestNativeRepository(t *testing.T) {
+	dir, git := gitFixture(t)
+	short := git("rev-parse", "--short", "HEAD~1")
+	refs := []string{"", "HEAD", "main", "feature", "refs/heads/feature", "HEAD~1", "HEAD^", "feature~2", "feature^^", "v1", "v1^{tree}", short}
+
+	t.Run("loose objects", func(t *testing.T) {
+		assertSameRepository(t, dir, refs)
+	})
+
+	git("gc", "-q", "--aggressive")
+	git("pack-refs", "--all")
+
+	t.Run("packfiles", func(t *testing.T) {
+		assertSameRepository(t, dir, refs)
+	})
+
+	git("update-index", "--index-version", "4")
+
+	t.Run("index v4", func(t *testing.T) {
+		assertSameRepository(t, dir, []string{""})
+	})
+
+	worktree := filepath.Join(t.TempDir(), "wt")
+	git("worktree", "add", "-q", worktree, "feature")
+
+	t.Run("worktree", func(t *testing.T) {
+		assertSameRepository(t, filepath.Join(worktree, "src"), []string{"", "HEAD", "main", "HEAD~1"})
+	})
+}
+
+func TestNativeRepositoryErrors(t *testing.T) {
+	dir, _ := gitFixture(t)
+
+	native, err := OpenNativeRepository(filepath.Join(dir, "src", "app"))
+	require.NoError(t, err)
+	assert.Equal(t, dir, native.Root())
+
+	_, err = native.LsFiles("no-such-branch")
+	assert.EqualError(t, err, `unknown revision "no-such-branch"`)
+
+	_, err = native.ResolveRef("no-such-branch")
+	assert.EqualError(t, err, `unknown revision "no-such-branch"`)
+
+	_, err = native.LsFiles("main@{1}")
+	assert.ErrorIs(t, err, errUnsupported)
+
+	_, err = native.ReadFile("HEAD", "src/missing.go")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = native.ReadFile("HEAD", "src")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// The fallback handles what the native reader can't
+	repo, err := OpenRepository(dir)
+	require.NoError(t, err)
+	files, err := repo.LsFiles("main@{0}")
+	require.NoError(t, err)
+	assert.Contains(t, files, "src/app/main.go")
+
+	name, err := repo.ResolveRef("main@{0}")
+	require.NoError(t, err)
+	assert.Len(t, name, 40)
+}
+
+func TestLsUntrackedFiles(t *testing.T) {
+	dir, _ := gitFixture(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "new.go"), []byte("package src\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "debug.log"), []byte("debug\n"), 0o644))
+
+	repo, err := OpenRepository(dir)
+	require.NoError(t, err)
+
+	files, err := repo.LsUntrackedFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gitignore", "src/new.go"}, files)
+}
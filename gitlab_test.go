@@ -0,0 +1,84 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gitlabFile = `* @org/platform
+
+[Docs] @writers
+*.md
+!/CHANGELOG.md
+
+^[Backend][2] @org/backend/leads
+/api/ @john.doe
+/api/generated/
+!/api/generated/
+`
+
+func TestParseGitLab(t *testing.T) {
+	rules, err := ParseFile(strings.NewReader(gitlabFile), WithDialect(DialectGitLab))
+	require.NoError(t, err)
+	require.Len(t, rules, 6)
+
+	assert.Equal(t, "", rules[0].Section)
+	assert.Equal(t, "Docs", rules[1].Section)
+	assert.Equal(t, mustOwners(t, "@writers"), rules[1].Owners)
+	assert.True(t, rules[2].Negated)
+	assert.Empty(t, rules[2].Owners)
+	assert.Equal(t, "Backend", rules[3].Section)
+	assert.Equal(t, []Owner{{Value: "john.doe", Type: UsernameOwner}}, rules[3].Owners)
+	assert.Equal(t, []Owner{{Value: "org/backend/leads", Type: TeamOwner}}, rules[4].Owners)
+
+	// Section headers are kept with the comments, and default owners aren't written out
+	doc, err := ParseDocument(strings.NewReader(gitlabFile), WithDialect(DialectGitLab))
+	require.NoError(t, err)
+	assert.Equal(t, gitlabFile, doc.String())
+
+	// Owners from every section apply, and an exclusion only affects its own section, wherever
+	// it appears in the section
+	listing, err := ListOwners(rules, []string{"README.md", "CHANGELOG.md", "api/server.go", "api/generated/types.go"}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, Owners{
+		{Path: "README.md", Owners: mustOwners(t, "@org/platform", "@writers")},
+		{Path: "CHANGELOG.md", Owners: mustOwners(t, "@org/platform")},
+		{Path: "api/server.go", Owners: []Owner{{Value: "org/platform", Type: TeamOwner}, {Value: "john.doe", Type: UsernameOwner}}},
+		{Path: "api/generated/types.go", Owners: mustOwners(t, "@org/platform")},
+	}, listing)
+
+	// Matchers and rulesets agree on the rules that apply
+	matcher := NewMatcher(rules)
+	ruleset := Ruleset(rules)
+	for _, path := range []string{"README.md", "CHANGELOG.md", "api/server.go", "api/generated/types.go", "go.mod"} {
+		expected, err := ruleset.MatchAll(path)
+		require.NoError(t, err)
+		actual, err := matcher.MatchAll(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, path)
+	}
+
+	matched, err := matcher.MatchAll("api/generated/types.go")
+	require.NoError(t, err)
+	assert.Equal(t, []*Rule{&rules[0], &rules[5]}, matched)
+	rule, err := matcher.Match("api/generated/types.go")
+	require.NoError(t, err)
+	assert.Equal(t, &rules[0], rule)
+}
+
+func TestParseNegationErrors(t *testing.T) {
+	_, err := ParseFile(strings.NewReader("* @alice\n!/docs/\n"))
+	assert.EqualError(t, err, "line 2: negation is not supported by GitHub")
+
+	_, err = ParseFile(strings.NewReader("!/docs/ @alice\n"), WithDialect(DialectGitLab))
+	assert.EqualError(t, err, "line 1: exclusion !/docs/ can't have owners")
+
+	_, err = ParseFile(strings.NewReader("[Docs] not-an-owner\n"), WithDialect(DialectGitLab))
+	assert.EqualError(t, err, "line 1: invalid owner format 'not-an-owner'")
+
+	_, err = ParseDialect("gerrit")
+	assert.EqualError(t, err, `unknown dialect "gerrit": must be github, bitbucket or gitlab`)
+}
@@ -0,0 +1,619 @@
+package codeowners
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const hashSize = 20
+
+// hash is a SHA-1 object name.
+type hash [hashSize]byte
+
+func (h hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+func parseHash(s string) (hash, error) {
+	var h hash
+	if len(s) != 2*hashSize {
+		return h, fmt.Errorf("invalid object name %q", s)
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, fmt.Errorf("invalid object name %q", s)
+	}
+	return h, nil
+}
+
+type objType int
+
+// Object types, numbered as in packfiles.
+const (
+	objCommit   objType = 1
+	objTree     objType = 2
+	objBlob     objType = 3
+	objTag      objType = 4
+	objOfsDelta objType = 6
+	objRefDelta objType = 7
+)
+
+var objTypeNames = map[string]objType{
+	"commit": objCommit,
+	"tree":   objTree,
+	"blob":   objBlob,
+	"tag":    objTag,
+}
+
+func (t objType) String() string {
+	for name, typ := range objTypeNames {
+		if typ == t {
+			return name
+		}
+	}
+	return "object type " + strconv.Itoa(int(t))
+}
+
+// objectStore reads objects from an objects directory and any alternates it lists.
+type objectStore struct {
+	dirs []string
+
+	mu     sync.Mutex
+	packs  []*packfile
+	loaded bool
+}
+
+func newObjectStore(dir string) *objectStore {
+	dirs := []string{dir}
+
+	// Alternates name further object directories, one per line, relative to this one.
+	if data, err := os.ReadFile(filepath.Join(dir, "info", "alternates")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(dir, line)
+			}
+			dirs = append(dirs, line)
+		}
+	}
+
+	return &objectStore{dirs: dirs}
+}
+
+// read returns the type and contents of an object.
+func (s *objectStore) read(h hash) (objType, []byte, error) {
+	name := h.String()
+	for _, dir := range s.dirs {
+		typ, data, err := readLooseObject(filepath.Join(dir, name[:2], name[2:]))
+		if err == nil {
+			return typ, data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return 0, nil, err
+		}
+	}
+
+	packs, err := s.loadPacks()
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, pack := range packs {
+		if offset, ok := pack.find(h); ok {
+			return pack.readAt(offset, s)
+		}
+	}
+
+	return 0, nil, fmt.Errorf("object %s not found", h)
+}
+
+// expand returns the unique object whose name starts with the given hex prefix.
+func (s *objectStore) expand(prefix string) (hash, error) {
+	found := make(map[hash]bool)
+
+	for _, dir := range s.dirs {
+		entries, err := os.ReadDir(filepath.Join(dir, prefix[:2]))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if name := prefix[:2] + entry.Name(); strings.HasPrefix(name, prefix) {
+				if h, err := parseHash(name); err == nil {
+					found[h] = true
+				}
+			}
+		}
+	}
+
+	packs, err := s.loadPacks()
+	if err != nil {
+		return hash{}, err
+	}
+	for _, pack := range packs {
+		for _, h := range pack.withPrefix(prefix) {
+			found[h] = true
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return hash{}, fmt.Errorf("unknown revision %q", prefix)
+	case 1:
+		for h := range found {
+			return h, nil
+		}
+	}
+	return hash{}, fmt.Errorf("short object name %s is ambiguous", prefix)
+}
+
+func (s *objectStore) loadPacks() ([]*packfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loaded {
+		return s.packs, nil
+	}
+
+	for _, dir := range s.dirs {
+		indexes, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		if err != nil {
+			return nil, err
+		}
+		for _, idx := range indexes {
+			pack, err := openPackfile(idx)
+			if err != nil {
+				return nil, err
+			}
+			s.packs = append(s.packs, pack)
+		}
+	}
+
+	s.loaded = true
+	return s.packs, nil
+}
+
+func readLooseObject(path string) (objType, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	z, err := zlib.NewReader(f)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer z.Close()
+
+	data, err := io.ReadAll(z)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Loose objects start with a "<type> <size>\x00" header
+	nul := bytes.IndexByte(data, 0)
+	space := bytes.IndexByte(data, ' ')
+	if nul < 0 || space < 0 || space > nul {
+		return 0, nil, fmt.Errorf("%s: malformed object header", path)
+	}
+	typ, ok := objTypeNames[string(data[:space])]
+	if !ok {
+		return 0, nil, fmt.Errorf("%s: unknown object type %q", path, data[:space])
+	}
+	return typ, data[nul+1:], nil
+}
+
+// packfile is a pack and its version 2 index.
+type packfile struct {
+	path    string
+	fanout  [256]uint32
+	names   []byte
+	offsets []byte
+	large   []byte
+
+	mu    sync.Mutex
+	file  *os.File
+	cache map[int64]cachedObject
+	size  int
+}
+
+type cachedObject struct {
+	typ  objType
+	data []byte
+}
+
+// maxPackCache bounds the memory used to cache delta bases, in bytes.
+const maxPackCache = 64 << 20
+
+func openPackfile(idxPath string) (*packfile, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte("\377tOc")) || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, fmt.Errorf("%s: pack index version is %w", idxPath, errUnsupported)
+	}
+
+	p := &packfile{path: strings.TrimSuffix(idxPath, ".idx") + ".pack", cache: make(map[int64]cachedObject)}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+4*i:])
+	}
+
+	count := int(p.fanout[255])
+	names := 8 + 256*4
+	crcs := names + count*hashSize
+	offsets := crcs + count*4
+	large := offsets + count*4
+	if len(idx) < large {
+		return nil, fmt.Errorf("%s: truncated pack index", idxPath)
+	}
+
+	p.names = idx[names:crcs]
+	p.offsets = idx[offsets:large]
+	p.large = idx[large:]
+	return p, nil
+}
+
+func (p *packfile) name(i int) []byte {
+	return p.names[i*hashSize : (i+1)*hashSize]
+}
+
+// find returns the offset of an object in the pack.
+func (p *packfile) find(h hash) (int64, bool) {
+	lo, hi := p.bucket(h[0])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.name(lo+i), h[:]) >= 0
+	})
+	if i == hi || !bytes.Equal(p.name(i), h[:]) {
+		return 0, false
+	}
+
+	offset := binary.BigEndian.Uint32(p.offsets[4*i:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	// Offsets over 2GB are stored in a separate table of 64-bit values
+	i = int(offset & 0x7fffffff)
+	return int64(binary.BigEndian.Uint64(p.large[8*i:])), true
+}
+
+// bucket returns the range of index entries whose names start with the given byte.
+func (p *packfile) bucket(first byte) (int, int) {
+	lo := 0
+	if first > 0 {
+		lo = int(p.fanout[first-1])
+	}
+	return lo, int(p.fanout[first])
+}
+
+func (p *packfile) withPrefix(prefix string) []hash {
+	first, err := strconv.ParseUint(prefix[:2], 16, 8)
+	if err != nil {
+		return nil
+	}
+
+	var out []hash
+	lo, hi := p.bucket(byte(first))
+	for i := lo; i < hi; i++ {
+		var h hash
+		copy(h[:], p.name(i))
+		if strings.HasPrefix(h.String(), prefix) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// readAt reads the object at the given offset, resolving deltas.
+func (p *packfile) readAt(offset int64, store *objectStore) (objType, []byte, error) {
+	p.mu.Lock()
+	if cached, ok := p.cache[offset]; ok {
+		p.mu.Unlock()
+		return cached.typ, cached.data, nil
+	}
+	if p.file == nil {
+		f, err := os.Open(p.path)
+		if err != nil {
+			p.mu.Unlock()
+			return 0, nil, err
+		}
+		p.file = f
+	}
+	p.mu.Unlock()
+
+	r := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+
+	// Each entry starts with its type and inflated size as a variable-length integer
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ := objType((b >> 4) & 7)
+	size := int64(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(b&0x7f) << shift
+	}
+
+	var baseType objType
+	var base []byte
+
+	switch typ {
+	case objOfsDelta:
+		// The base is at a negative offset from this entry
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		rel := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = r.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			rel = ((rel + 1) << 7) | int64(b&0x7f)
+		}
+		if baseType, base, err = p.readAt(offset-rel, store); err != nil {
+			return 0, nil, err
+		}
+
+	case objRefDelta:
+		var h hash
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			return 0, nil, err
+		}
+		if baseType, base, err = store.read(h); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	z, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", p.path, err)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(z, data); err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", p.path, err)
+	}
+
+	if base != nil {
+		typ = baseType
+		if data, err = applyDelta(base, data); err != nil {
+			return 0, nil, fmt.Errorf("%s: %w", p.path, err)
+		}
+	}
+
+	p.mu.Lock()
+	if p.size+len(data) > maxPackCache {
+		p.cache = make(map[int64]cachedObject)
+		p.size = 0
+	}
+	p.cache[offset] = cachedObject{typ, data}
+	p.size += len(data)
+	p.mu.Unlock()
+
+	return typ, data, nil
+}
+
+// applyDelta rebuilds an object from its base and a delta of copy and insert instructions.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errCorrupt := errors.New("corrupt delta")
+
+	readSize := func() (int, error) {
+		size, shift := 0, 0
+		for {
+			if len(delta) == 0 {
+				return 0, errCorrupt
+			}
+			b := delta[0]
+			delta = delta[1:]
+			size |= int(b&0x7f) << shift
+			shift += 7
+			if b&0x80 == 0 {
+				return size, nil
+			}
+		}
+	}
+
+	baseSize, err := readSize()
+	if err != nil || baseSize != len(base) {
+		return nil, errCorrupt
+	}
+	size, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, size)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 == 0 {
+			// Insert the next op bytes verbatim
+			if op == 0 || int(op) > len(delta) {
+				return nil, errCorrupt
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+			continue
+		}
+
+		// Copy from the base: the low bits say which offset and size bytes follow
+		var offset, length int
+		for i := 0; i < 7; i++ {
+			if op&(1<<i) == 0 {
+				continue
+			}
+			if len(delta) == 0 {
+				return nil, errCorrupt
+			}
+			if i < 4 {
+				offset |= int(delta[0]) << (8 * i)
+			} else {
+				length |= int(delta[0]) << (8 * (i - 4))
+			}
+			delta = delta[1:]
+		}
+		if length == 0 {
+			length = 0x10000
+		}
+		if offset+length > len(base) {
+			return nil, errCorrupt
+		}
+		out = append(out, base[offset:offset+length]...)
+	}
+
+	if len(out) != size {
+		return nil, errCorrupt
+	}
+	return out, nil
+}
+
+// File modes used in trees.
+const (
+	modeTree = 0o40000
+)
+
+type treeEntry struct {
+	mode uint32
+	name string
+	hash hash
+}
+
+// parseTree decodes a tree object: a sequence of "<octal mode> <name>\x00<binary hash>" entries.
+func parseTree(data []byte) ([]treeEntry, error) {
+	var entries []treeEntry
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || len(data) < nul+1+hashSize {
+			return nil, errors.New("malformed tree object")
+		}
+
+		mode, err := strconv.ParseUint(string(data[:space]), 8, 32)
+		if err != nil {
+			return nil, errors.New("malformed tree object")
+		}
+
+		entry := treeEntry{mode: uint32(mode), name: string(data[space+1 : nul])}
+		copy(entry.hash[:], data[nul+1:])
+		entries = append(entries, entry)
+
+		data = data[nul+1+hashSize:]
+	}
+	return entries, nil
+}
+
+// readIndex lists the paths in a git index file, each path once, in index order.
+func readIndex(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make([]string, 0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 12 || !bytes.Equal(data[:4], []byte("DIRC")) {
+		return nil, fmt.Errorf("%s: not a git index", path)
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("%s: index version %d is %w", path, version, errUnsupported)
+	}
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+
+	// Each entry has 40 bytes of stat data, a hash and 16 bits of flags before the path
+	const fixed = 40 + hashSize + 2
+	const extendedFlag = 0x4000
+
+	files := make([]string, 0, count)
+	pos := 12
+	previous := ""
+
+	for i := 0; i < count; i++ {
+		if pos+fixed > len(data) {
+			return nil, fmt.Errorf("%s: truncated index", path)
+		}
+		entry := pos
+
+		mode := binary.BigEndian.Uint32(data[entry+24:])
+		flags := binary.BigEndian.Uint16(data[entry+40+hashSize:])
+		pos += fixed
+		if version >= 3 && flags&extendedFlag != 0 {
+			pos += 2
+		}
+
+		var name string
+		if version == 4 {
+			// Paths are prefix-compressed: strip n bytes from the previous path, then append
+			strip, n := readOffsetVarint(data[pos:])
+			if n == 0 || strip > len(previous) {
+				return nil, fmt.Errorf("%s: corrupt index", path)
+			}
+			pos += n
+			nul := bytes.IndexByte(data[pos:], 0)
+			if nul < 0 {
+				return nil, fmt.Errorf("%s: corrupt index", path)
+			}
+			name = previous[:len(previous)-strip] + string(data[pos:pos+nul])
+			pos += nul + 1
+		} else {
+			nul := bytes.IndexByte(data[pos:], 0)
+			if nul < 0 {
+				return nil, fmt.Errorf("%s: corrupt index", path)
+			}
+			name = string(data[pos : pos+nul])
+			// Entries are padded with 1-8 NUL bytes to a multiple of eight
+			pos = entry + (pos-entry+nul+8)&^7
+		}
+
+		if mode&0o170000 == modeTree {
+			// Sparse indexes store whole directories as single entries
+			return nil, fmt.Errorf("%s: sparse index is %w", path, errUnsupported)
+		}
+
+		// Unmerged paths appear once per conflict stage
+		if name != previous || i == 0 {
+			files = append(files, name)
+		}
+		previous = name
+	}
+
+	return files, nil
+}
+
+// readOffsetVarint decodes the variable-length integers used by index v4 and ofs-delta entries,
+// returning the value and the number of bytes read.
+func readOffsetVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	value := int(data[0] & 0x7f)
+	n := 1
+	for data[n-1]&0x80 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+		value = ((value + 1) << 7) | int(data[n]&0x7f)
+		n++
+	}
+	return value, n
+}
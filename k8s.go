@@ -0,0 +1,588 @@
+package codeowners
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// K8sOwners is a Kubernetes-style OWNERS file, as used by Prow. Fields not relevant to ownership,
+// such as labels, are ignored.
+type K8sOwners struct {
+	Approvers []string         `yaml:"approvers,omitempty"`
+	Reviewers []string         `yaml:"reviewers,omitempty"`
+	Options   K8sOwnersOptions `yaml:"options,omitempty"`
+	// Filters give the files whose paths, relative to the directory, match a regular expression
+	// their own approvers and reviewers. A file's owners are the union of those of every filter it
+	// matches.
+	Filters map[string]K8sOwnersFilter `yaml:"filters,omitempty"`
+}
+
+// K8sOwnersOptions are the options of a Kubernetes OWNERS file.
+type K8sOwnersOptions struct {
+	NoParentOwners bool `yaml:"no_parent_owners,omitempty"`
+}
+
+// K8sOwnersFilter is the owners of the files matching a filter of a Kubernetes OWNERS file.
+type K8sOwnersFilter struct {
+	Approvers []string `yaml:"approvers,omitempty"`
+	Reviewers []string `yaml:"reviewers,omitempty"`
+}
+
+// K8sOptions controls how Kubernetes OWNERS files are converted to rules.
+type K8sOptions struct {
+	// Aliases are the groups of users defined in OWNERS_ALIASES, which are expanded to their
+	// members.
+	Aliases map[string][]string
+	// AliasOrg, if set, turns aliases into teams of the organization instead, so that the alias
+	// payments becomes @AliasOrg/payments.
+	AliasOrg string
+	// IncludeReviewers makes reviewers owners, as well as approvers.
+	IncludeReviewers bool
+}
+
+// ParseK8sOwnersAliases parses a Kubernetes OWNERS_ALIASES file, returning the members of each
+// alias.
+func ParseK8sOwnersAliases(f io.Reader) (map[string][]string, error) {
+	var file struct {
+		Aliases map[string][]string `yaml:"aliases"`
+	}
+	if err := yaml.NewDecoder(f).Decode(&file); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if file.Aliases == nil {
+		file.Aliases = make(map[string][]string)
+	}
+	return file.Aliases, nil
+}
+
+// K8sOwnersParser returns a parser of Kubernetes OWNERS files for LoadNestedFS. The approvers of a
+// directory, and the reviewers with IncludeReviewers, become its owners. A filter for ".*" adds
+// owners to the whole directory, and other filters must match literal paths or extensions, such as
+// "^docs/", "^(?:api|db)\.go$" or "\.proto$", which are rewritten as patterns.
+func K8sOwnersParser(opts K8sOptions) OwnersParser {
+	return func(f io.Reader) (*OwnersFile, error) {
+		var owners K8sOwners
+		if err := yaml.NewDecoder(f).Decode(&owners); err != nil && err != io.EOF {
+			return nil, err
+		}
+		return owners.ownersFile(opts)
+	}
+}
+
+func (k *K8sOwners) ownersFile(opts K8sOptions) (*OwnersFile, error) {
+	file := &OwnersFile{NoParent: k.Options.NoParentOwners}
+
+	var err error
+	if file.Owners, err = opts.owners(k.Approvers, k.Reviewers); err != nil {
+		return nil, err
+	}
+
+	filters := make([]string, 0, len(k.Filters))
+	for filter := range k.Filters {
+		filters = append(filters, filter)
+	}
+	sort.Strings(filters)
+
+	for _, filter := range filters {
+		owners, err := opts.owners(k.Filters[filter].Approvers, k.Filters[filter].Reviewers)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns, err := filterPatterns(filter)
+		if err != nil {
+			return nil, err
+		}
+		if patterns == nil {
+			file.Owners = unionOwners(file.Owners, owners)
+			continue
+		}
+		for _, pattern := range patterns {
+			file.Rules = append(file.Rules, OwnersRule{Pattern: pattern, Owners: owners})
+		}
+	}
+
+	return file, nil
+}
+
+// owners converts the users and aliases listed in an OWNERS file to owners.
+func (opts K8sOptions) owners(approvers, reviewers []string) ([]Owner, error) {
+	names := approvers
+	if opts.IncludeReviewers {
+		names = append(append([]string{}, approvers...), reviewers...)
+	}
+
+	owners := make([]Owner, 0, len(names))
+	for _, name := range names {
+		members := []string{name}
+		if alias, ok := opts.Aliases[name]; ok && opts.AliasOrg == "" {
+			members = alias
+		} else if ok {
+			members = []string{opts.AliasOrg + "/" + name}
+		}
+
+		for _, member := range members {
+			owner, err := newOwner("@" + strings.TrimPrefix(member, "@"))
+			if err != nil {
+				return nil, err
+			}
+			owners = unionOwners(owners, []Owner{owner})
+		}
+	}
+	return owners, nil
+}
+
+// maxFilterPatterns limits the number of patterns a filter expands to.
+const maxFilterPatterns = 1000
+
+// filterPatterns rewrites the regular expression of a filter as patterns relative to the directory,
+// or returns nil for a filter matching every file. Each path or extension the filter matches, such
+// as both in ^(?:a\.go|docs/), becomes a pattern.
+func filterPatterns(filter string) ([]string, error) {
+	re, err := syntax.Parse(filter, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %v", filter, err)
+	}
+	re = re.Simplify()
+
+	nodes := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		nodes = re.Sub
+	} else if re.Op == syntax.OpEmptyMatch {
+		nodes = nil
+	}
+
+	begin := len(nodes) > 0 && nodes[0].Op == syntax.OpBeginText
+	if begin {
+		nodes = nodes[1:]
+	}
+	end := len(nodes) > 0 && nodes[len(nodes)-1].Op == syntax.OpEndText
+	if end {
+		nodes = nodes[:len(nodes)-1]
+	}
+
+	// A leading or trailing .* is the same as no anchor
+	if len(nodes) > 0 && isAnyString(nodes[0]) {
+		nodes, begin = nodes[1:], false
+	}
+	if len(nodes) > 0 && isAnyString(nodes[len(nodes)-1]) {
+		nodes, end = nodes[:len(nodes)-1], false
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	invalid := fmt.Errorf("filter %q can't be written as patterns: use literal paths such as ^docs/ or extensions such as \\.go$", filter)
+	literals, ok := expandRegexp(&syntax.Regexp{Op: syntax.OpConcat, Sub: nodes})
+	if !ok {
+		return nil, invalid
+	}
+
+	patterns := make([]string, 0, len(literals))
+	for _, literal := range literals {
+		literal = escapePattern(literal)
+		switch {
+		case literal == "":
+			return nil, invalid
+		case begin && end:
+			patterns = append(patterns, "/"+literal)
+		case begin && strings.HasSuffix(literal, "/"):
+			patterns = append(patterns, "/"+literal)
+		case begin:
+			patterns = append(patterns, "/"+literal+"*")
+		case end && !strings.Contains(literal, "/"):
+			patterns = append(patterns, "*"+literal)
+		default:
+			return nil, invalid
+		}
+	}
+	return patterns, nil
+}
+
+// expandRegexp returns the strings matched by a regular expression without anchors that matches
+// a small, finite set of strings, and reports whether it does.
+func expandRegexp(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil, false
+		}
+		return []string{string(re.Rune)}, true
+	case syntax.OpCapture:
+		return expandRegexp(re.Sub[0])
+	case syntax.OpCharClass:
+		var out []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if len(out) == maxFilterPatterns {
+					return nil, false
+				}
+				out = append(out, string(r))
+			}
+		}
+		return out, true
+	case syntax.OpAlternate:
+		var out []string
+		for _, sub := range re.Sub {
+			strs, ok := expandRegexp(sub)
+			if !ok || len(out)+len(strs) > maxFilterPatterns {
+				return nil, false
+			}
+			out = append(out, strs...)
+		}
+		return out, true
+	case syntax.OpConcat:
+		out := []string{""}
+		for _, sub := range re.Sub {
+			strs, ok := expandRegexp(sub)
+			if !ok || len(out)*len(strs) > maxFilterPatterns {
+				return nil, false
+			}
+			next := make([]string, 0, len(out)*len(strs))
+			for _, prefix := range out {
+				for _, s := range strs {
+					next = append(next, prefix+s)
+				}
+			}
+			out = next
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// isAnyString reports whether the node is .*, which matches any string.
+func isAnyString(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpStar && (re.Sub[0].Op == syntax.OpAnyCharNotNL || re.Sub[0].Op == syntax.OpAnyChar)
+}
+
+// K8sExport is a tree of Kubernetes OWNERS files generated by ExportK8sOwners.
+type K8sExport struct {
+	// Files are the OWNERS files by directory, with "" for the root.
+	Files map[string]*K8sOwners
+	// Aliases are the teams, which OWNERS files refer to by name, along with their members, which
+	// are unknown and left empty.
+	Aliases map[string][]string
+	// AliasOrg is the organization of the teams.
+	AliasOrg string
+	// Warnings describe owners that couldn't be exported.
+	Warnings []string
+}
+
+// ExportK8sOwners returns Kubernetes OWNERS files giving the files the same owners as in the
+// listing. Each directory's approvers are the owners most of its files and subdirectories share,
+// and filters list the extra owners of the others. Directories whose owners don't include those
+// of their parent have an OWNERS file of their own, with no_parent_owners if the inherited
+// approvers don't apply. Teams become aliases named after the team, and email owners, which
+// Kubernetes doesn't support, are left out with a warning.
+//
+// The result is checked by converting it back to rules, with the team organization as AliasOrg.
+func ExportK8sOwners(files Owners) (*K8sExport, error) {
+	export := &K8sExport{Files: make(map[string]*K8sOwners), Aliases: make(map[string][]string)}
+
+	// The owners of each file, as they are listed in OWNERS files
+	names := make(map[string][]string)
+	tree := &generateNode{isDir: true}
+	dirs := map[string]*generateNode{"": tree}
+	var dir func(p string) *generateNode
+	dir = func(p string) *generateNode {
+		if node, ok := dirs[p]; ok {
+			return node
+		}
+		node := &generateNode{path: p, isDir: true}
+		parent := dir(parentDir(p))
+		parent.children = append(parent.children, node)
+		dirs[p] = node
+		return node
+	}
+
+	warned := make(map[Owner]bool)
+	for _, file := range files {
+		list, err := export.ownerNames(file.Owners, warned)
+		if err != nil {
+			return nil, err
+		}
+		label := strings.Join(list, " ")
+		names[label] = list
+
+		parent := dir(parentDir(file.Path))
+		parent.children = append(parent.children, &generateNode{path: file.Path, label: label})
+	}
+
+	labels := make(map[*generateNode]string)
+	var uniform func(n *generateNode) (string, bool)
+	uniform = func(n *generateNode) (string, bool) {
+		if !n.isDir {
+			return n.label, true
+		}
+		if label, ok := labels[n]; ok {
+			return label, label != "\x00"
+		}
+		label, same := "", true
+		for i, child := range n.children {
+			l, ok := uniform(child)
+			if !ok || (i > 0 && l != label) {
+				same = false
+			}
+			label = l
+		}
+		if !same {
+			label = "\x00"
+		}
+		labels[n] = label
+		return label, same
+	}
+
+	var visit func(n *generateNode, inherited []string)
+	visit = func(n *generateNode, inherited []string) {
+		approvers, effective, noParent := chooseApprovers(n, inherited, uniform, names)
+		owners := &K8sOwners{Approvers: approvers, Filters: make(map[string]K8sOwnersFilter)}
+		owners.Options.NoParentOwners = noParent
+
+		// The files and subdirectories with each extra owners, which share a filter
+		files, subdirs := make(map[string][]string), make(map[string][]string)
+		for _, child := range n.children {
+			rel := strings.TrimPrefix(strings.TrimPrefix(child.path, n.path), "/")
+			label, ok := uniform(child)
+			if !ok || !namesSubset(effective, names[label]) {
+				// Directories with owners of their own get an OWNERS file
+				visit(child, effective)
+				continue
+			}
+
+			extra := namesDifference(names[label], effective)
+			if len(extra) == 0 {
+				continue
+			}
+			extraLabel := strings.Join(extra, " ")
+			names[extraLabel] = extra
+			if child.isDir {
+				subdirs[extraLabel] = append(subdirs[extraLabel], regexp.QuoteMeta(rel))
+			} else {
+				files[extraLabel] = append(files[extraLabel], regexp.QuoteMeta(rel))
+			}
+		}
+		for label, rels := range files {
+			for _, filter := range alternations("^", rels, "$") {
+				owners.Filters[filter] = K8sOwnersFilter{Approvers: names[label]}
+			}
+		}
+		for label, rels := range subdirs {
+			for _, filter := range alternations("^", rels, "/") {
+				owners.Filters[filter] = K8sOwnersFilter{Approvers: names[label]}
+			}
+		}
+
+		if len(owners.Approvers) > 0 || len(owners.Filters) > 0 || noParent {
+			export.Files[n.path] = owners
+		}
+	}
+	visit(tree, nil)
+
+	return export, export.verify(files)
+}
+
+// chooseApprovers returns the approvers of a directory's OWNERS file, the approvers they give
+// every file beneath it along with the inherited ones, and whether it sets no_parent_owners. Since
+// filters can only add owners, the approvers of every file directly in the directory must include
+// them. Of those choices, the owners shared by the most files and subdirectories are picked, so
+// filters are only needed for the exceptions.
+func chooseApprovers(n *generateNode, inherited []string, uniform func(*generateNode) (string, bool), names map[string][]string) ([]string, []string, bool) {
+	type choice struct {
+		approvers, effective []string
+		noParent             bool
+	}
+
+	// Keeping the inherited approvers comes first, then the owners of each child, then none
+	choices := []choice{{nil, inherited, false}}
+	seen := make(map[string]bool)
+	for _, child := range n.children {
+		label, ok := uniform(child)
+		if !ok || seen[label] || label == "" {
+			continue
+		}
+		seen[label] = true
+		if namesSubset(inherited, names[label]) {
+			choices = append(choices, choice{namesDifference(names[label], inherited), names[label], false})
+		} else {
+			choices = append(choices, choice{names[label], names[label], true})
+		}
+	}
+	choices = append(choices, choice{nil, nil, len(inherited) > 0})
+	sort.SliceStable(choices[1:len(choices)-1], func(i, j int) bool {
+		return strings.Join(choices[1+i].effective, " ") < strings.Join(choices[1+j].effective, " ")
+	})
+
+	best, bestCount := choices[len(choices)-1], -1
+	for _, c := range choices {
+		count, valid := 0, true
+		for _, child := range n.children {
+			label, ok := uniform(child)
+			if !child.isDir && !namesSubset(c.effective, names[label]) {
+				valid = false
+				break
+			}
+			if ok && namesSubset(c.effective, names[label]) && namesSubset(names[label], c.effective) {
+				count++
+			}
+		}
+		if valid && count > bestCount {
+			best, bestCount = c, count
+		}
+	}
+	return best.approvers, best.effective, best.noParent
+}
+
+// namesSubset reports whether every name in a is in b.
+func namesSubset(a, b []string) bool {
+	for _, name := range a {
+		if !containsString(b, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// namesDifference returns the names in a that aren't in b.
+func namesDifference(a, b []string) []string {
+	var out []string
+	for _, name := range a {
+		if !containsString(b, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// maxFilterLength is the length beyond which filters are split, since YAML writes longer keys in
+// a form few people know.
+const maxFilterLength = 100
+
+// alternations returns regular expressions between prefix and suffix that together match the
+// given ones, each no longer than maxFilterLength unless a single one is.
+func alternations(prefix string, res []string, suffix string) []string {
+	sort.Strings(res)
+	var out []string
+	for len(res) > 0 {
+		n, length := 1, len(prefix)+len(res[0])+len(suffix)+len("(?:)")
+		for n < len(res) && length+len(res[n])+1 <= maxFilterLength {
+			length += len(res[n]) + 1
+			n++
+		}
+		if n == 1 {
+			out = append(out, prefix+res[0]+suffix)
+		} else {
+			out = append(out, prefix+"(?:"+strings.Join(res[:n], "|")+")"+suffix)
+		}
+		res = res[n:]
+	}
+	return out
+}
+
+// ownerNames returns the names of the owners in OWNERS files, recording the teams as aliases.
+func (e *K8sExport) ownerNames(owners []Owner, warned map[Owner]bool) ([]string, error) {
+	names := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		switch owner.Type {
+		case UsernameOwner:
+			names = append(names, owner.Value)
+		case TeamOwner:
+			org, team := owner.Org(), strings.TrimPrefix(owner.Value, owner.Org()+"/")
+			if e.AliasOrg != "" && org != e.AliasOrg {
+				return nil, fmt.Errorf("teams of both %s and %s can't be exported as aliases", e.AliasOrg, org)
+			}
+			e.AliasOrg = org
+			if _, ok := e.Aliases[team]; !ok {
+				e.Aliases[team] = []string{}
+			}
+			names = append(names, team)
+		case EmailOwner:
+			if !warned[owner] {
+				warned[owner] = true
+				e.Warnings = append(e.Warnings, fmt.Sprintf("%s left out: OWNERS files can't list email addresses", owner))
+			}
+		}
+	}
+	return names, nil
+}
+
+// verify checks that the OWNERS files give every file the owners it has in the listing, apart from
+// email owners. The order of the owners doesn't matter, as OWNERS files don't keep it.
+func (e *K8sExport) verify(files Owners) error {
+	opts := K8sOptions{Aliases: e.Aliases, AliasOrg: e.AliasOrg}
+	ownersFiles := make(map[string]*OwnersFile, len(e.Files))
+	names := make(map[string]string, len(e.Files))
+	for dir, k := range e.Files {
+		file, err := k.ownersFile(opts)
+		if err != nil {
+			return err
+		}
+		ownersFiles[dir] = file
+		names[dir] = e.Path(dir)
+	}
+
+	rules, err := composeNested(ownersFiles, names)
+	if err != nil {
+		return err
+	}
+
+	matcher := NewMatcher(rules)
+	for _, file := range files {
+		expected := make([]Owner, 0, len(file.Owners))
+		for _, owner := range file.Owners {
+			if owner.Type != EmailOwner && owner != Unowned {
+				expected = append(expected, owner)
+			}
+		}
+
+		rule, err := matcher.Match(file.Path)
+		if err != nil {
+			return err
+		}
+		var actual []Owner
+		if rule != nil {
+			actual = rule.Owners
+		}
+		if !sameOwners(actual, expected) {
+			return fmt.Errorf("exported OWNERS files give %s the owners %v instead of %v", file.Path, actual, expected)
+		}
+	}
+	return nil
+}
+
+// Path returns the path of the OWNERS file of a directory.
+func (e *K8sExport) Path(dir string) string {
+	if dir == "" {
+		return "OWNERS"
+	}
+	return dir + "/OWNERS"
+}
+
+// Marshal returns the contents of the OWNERS file of a directory.
+func (e *K8sExport) Marshal(dir string) ([]byte, error) {
+	return marshalYAML(e.Files[dir])
+}
+
+// MarshalAliases returns the contents of the OWNERS_ALIASES file.
+func (e *K8sExport) MarshalAliases() ([]byte, error) {
+	return marshalYAML(map[string]interface{}{"aliases": e.Aliases})
+}
+
+func marshalYAML(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), encoder.Close()
+}
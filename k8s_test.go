@@ -0,0 +1,209 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestK8sOwnersParser(t *testing.T) {
+	aliases, err := ParseK8sOwnersAliases(strings.NewReader(`aliases:
+  sig-payments:
+    - alice
+    - bob
+`))
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"OWNERS": {Data: []byte(`approvers:
+  - root-approver
+reviewers:
+  - root-reviewer
+labels:
+  - sig/core
+`)},
+		"payments/OWNERS": {Data: []byte(`approvers:
+  - sig-payments
+filters:
+  ".*":
+    approvers:
+      - carol
+  "\\.proto$":
+    approvers:
+      - api-reviewer
+  "^db/":
+    approvers:
+      - dba
+options:
+  no_parent_owners: true
+`)},
+	}
+
+	rules, err := LoadNestedFS(fsys, NestedOptions{Parse: K8sOwnersParser(K8sOptions{Aliases: aliases})})
+	require.NoError(t, err)
+
+	patterns := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		patterns = append(patterns, rule.String())
+	}
+	assert.Equal(t, []string{
+		"* @root-approver",
+		"/payments/ @alice @bob @carol",
+		"/payments/**/*.proto @api-reviewer @alice @bob @carol",
+		"/payments/db/ @dba @alice @bob @carol",
+	}, patterns)
+
+	// Aliases can be kept as teams, and reviewers included
+	rules, err = LoadNestedFS(fsys, NestedOptions{Parse: K8sOwnersParser(K8sOptions{
+		Aliases:          aliases,
+		AliasOrg:         "org",
+		IncludeReviewers: true,
+	})})
+	require.NoError(t, err)
+	assert.Equal(t, "* @root-approver @root-reviewer", rules[0].String())
+	assert.Equal(t, "/payments/ @org/sig-payments @carol", rules[1].String())
+}
+
+func TestFilterPatterns(t *testing.T) {
+	for filter, expected := range map[string][]string{
+		".*":                   nil,
+		"":                     nil,
+		`\.go$`:                {"*.go"},
+		`.*_test\.go$`:         {"*_test.go"},
+		`^docs/`:               {"/docs/"},
+		`^docs/.*`:             {"/docs/"},
+		`^Makefile$`:           {"/Makefile"},
+		`^cmd`:                 {"/cmd*"},
+		`^(?:api\.go|db\.go)$`: {"/api.go", "/db.go"},
+		`^(?:docs|site)/`:      {"/docs/", "/site/"},
+		`\.(pb|proto)$`:        {"*.pb", "*.proto"},
+		`^v[12]/`:              {"/v1/", "/v2/"},
+		`^my file\.txt$`:       {"/my\\ file.txt"},
+	} {
+		patterns, err := filterPatterns(filter)
+		if assert.NoError(t, err, filter) {
+			assert.Equal(t, expected, patterns, filter)
+		}
+	}
+
+	for _, filter := range []string{`^a+\.go$`, `^[^/]*\.go$`, `(?i)^docs/`, `docs`, `^(?:docs/|)`} {
+		_, err := filterPatterns(filter)
+		assert.Error(t, err, filter)
+	}
+	_, err := filterPatterns(`^a+\.go$`)
+	assert.EqualError(t, err, `filter "^a+\\.go$" can't be written as patterns: use literal paths such as ^docs/ or extensions such as \.go$`)
+}
+
+func TestExportK8sOwners(t *testing.T) {
+	rules := mustParse(t, `* @org/core
+/services/ @org/services
+/services/payments/ @alice @org/payments
+/services/payments/legacy.go
+/services/search/*.md docs@example.com
+`)
+	files := []string{
+		"README.md",
+		"go.mod",
+		"services/index.go",
+		"services/payments/api.go",
+		"services/payments/db/store.go",
+		"services/payments/legacy.go",
+		"services/search/query.go",
+		"services/search/README.md",
+	}
+	listing, err := ListOwners(rules, files, nil, false)
+	require.NoError(t, err)
+
+	export, err := ExportK8sOwners(listing)
+	require.NoError(t, err)
+
+	assert.Equal(t, "org", export.AliasOrg)
+	assert.Equal(t, map[string][]string{"core": {}, "services": {}, "payments": {}}, export.Aliases)
+	assert.Equal(t, []string{"docs@example.com left out: OWNERS files can't list email addresses"}, export.Warnings)
+
+	dirs := make([]string, 0, len(export.Files))
+	for dir := range export.Files {
+		dirs = append(dirs, export.Path(dir))
+	}
+	assert.ElementsMatch(t, []string{"OWNERS", "services/OWNERS", "services/payments/OWNERS", "services/search/OWNERS"}, dirs)
+
+	data, err := export.Marshal("")
+	require.NoError(t, err)
+	assert.Equal(t, "approvers:\n  - core\n", string(data))
+
+	data, err = export.Marshal("services")
+	require.NoError(t, err)
+	assert.Equal(t, "approvers:\n  - services\noptions:\n  no_parent_owners: true\n", string(data))
+
+	data, err = export.Marshal("services/payments")
+	require.NoError(t, err)
+	assert.Equal(t, `options:
+  no_parent_owners: true
+filters:
+  ^api\.go$:
+    approvers:
+      - alice
+      - payments
+  ^db/:
+    approvers:
+      - alice
+      - payments
+`, string(data))
+
+	data, err = export.MarshalAliases()
+	require.NoError(t, err)
+	assert.Equal(t, "aliases:\n  core: []\n  payments: []\n  services: []\n", string(data))
+}
+
+func TestExportK8sOwnersApprovers(t *testing.T) {
+	rules := mustParse(t, `* @org/core
+/src/ @alice @org/src
+/lib/ @org/core @bob
+/lib/vendor/ @org/core @bob @carol
+*.proto @bob
+`)
+	files := []string{"CODEOWNERS", "README.md", "src/a.go", "src/b.go", "src/c/d.go", "lib/a.go", "lib/b.go", "lib/vendor/x.go"}
+	listing, err := ListOwners(rules, files, nil, false)
+	require.NoError(t, err)
+
+	export, err := ExportK8sOwners(listing)
+	require.NoError(t, err)
+
+	dirs := make([]string, 0, len(export.Files))
+	for dir := range export.Files {
+		dirs = append(dirs, export.Path(dir))
+	}
+	assert.ElementsMatch(t, []string{"OWNERS", "src/OWNERS", "lib/OWNERS"}, dirs)
+
+	// Directories list their owners as approvers, so files added later are owned, and only add
+	// to those they inherit unless they don't include them
+	data, err := export.Marshal("src")
+	require.NoError(t, err)
+	assert.Equal(t, "approvers:\n  - alice\n  - src\noptions:\n  no_parent_owners: true\n", string(data))
+
+	data, err = export.Marshal("lib")
+	require.NoError(t, err)
+	assert.Equal(t, `approvers:
+  - bob
+filters:
+  ^vendor/:
+    approvers:
+      - carol
+`, string(data))
+}
+
+func TestExportK8sOwnersUniform(t *testing.T) {
+	export, err := ExportK8sOwners(Owners{
+		{Path: "a.go", Owners: mustOwners(t, "@alice")},
+		{Path: "b/c.go", Owners: mustOwners(t, "@alice")},
+	})
+	require.NoError(t, err)
+
+	data, err := export.Marshal("")
+	require.NoError(t, err)
+	assert.Equal(t, "approvers:\n  - alice\n", string(data))
+	assert.Empty(t, export.Aliases)
+}
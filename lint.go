@@ -10,11 +10,25 @@ import (
 
 // Rule is a CODEOWNERS rule that maps a gitignore-style path pattern to a set of owners.
 type Rule struct {
-	SourceLine      int
+	SourceLine int
+	// SourceFile is the file the rule was loaded from, for rules composed from several files.
+	SourceFile      string
 	leadingComment  string
 	trailingComment string
 	pattern         pattern
-	Owners          []string
+	Owners          []Owner
+	// Selections maps owners to how reviewers are selected from them, in Bitbucket files. Every
+	// owner without a selection is a reviewer.
+	Selections map[Owner]Selection
+	// Negated is set for exclusions, written !pattern, which leave matching files unowned by
+	// the rule's section, wherever the exclusion is in the section.
+	Negated bool
+	// Section is the name of the GitLab section the rule is in. Each section determines owners
+	// separately, and a file's owners are those of every section.
+	Section string
+	// defaultOwners is set when the owners are the default owners of the section, which aren't
+	// written with the rule.
+	defaultOwners bool
 }
 
 // RawPattern returns the rule's gitignore-style path pattern.
@@ -22,9 +36,37 @@ func (r *Rule) RawPattern() string {
 	if r == nil {
 		return ""
 	}
+	if r.Negated {
+		return "!" + r.pattern.pattern
+	}
 	return r.pattern.pattern
 }
 
+// UnsupportedSyntax describes the syntax of the rule's pattern that GitHub doesn't support, such
+// as "character class [a-z]", or returns "" if there is none. Such patterns are only parsed with
+// WithGitignorePatterns or in other dialects, and GitHub reads them differently or not at all.
+func (r *Rule) UnsupportedSyntax() string {
+	if r.Negated {
+		return "negation"
+	}
+
+	runes := []rune(r.pattern.pattern)
+	if len(runes) > 1 && runes[0] == '\\' && runes[1] == '#' {
+		return "escaped leading #"
+	}
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '[':
+			if _, end, err := characterClass(runes, i, false); err == nil && end >= 0 {
+				return "character class " + string(runes[i:end+1])
+			}
+		}
+	}
+	return ""
+}
+
 // Match tests whether the provided matches the rule's pattern.
 func (r *Rule) Match(path string) (bool, error) {
 	return r.pattern.match(path)
@@ -35,9 +77,17 @@ func (r *Rule) String() string {
 	if r.leadingComment != "" {
 		b.WriteString(r.leadingComment)
 	}
-	b.WriteString(r.pattern.pattern)
+	b.WriteString(r.RawPattern())
+	var selection Selection
 	for _, owner := range r.Owners {
-		b.WriteString(" " + owner)
+		if r.defaultOwners {
+			break
+		}
+		if r.Selections[owner] != selection {
+			selection = r.Selections[owner]
+			b.WriteString(" " + selection.String())
+		}
+		b.WriteString(" " + owner.String())
 	}
 	if r.trailingComment != "" {
 		b.WriteString(" " + r.trailingComment)
@@ -51,20 +101,27 @@ type Ruleset []Rule
 
 // Match finds the last rule in the ruleset that matches the path provided. When determining the
 // ownership of a file using CODEOWNERS, order matters, and the last matching rule takes precedence.
+// Exclusions take precedence over the other rules of their section, and in GitLab files with
+// several sections, the last rule giving the path owners is returned; see MatchAll.
 func (r Ruleset) Match(path string) (*Rule, error) {
-	for i := len(r) - 1; i >= 0; i-- {
-		rule := &r[i]
-		match, err := rule.Match(path)
-		if match || err != nil {
-			return rule, err
-		}
+	rules, err := r.MatchAll(path)
+	return decisiveRule(rules), err
+}
+
+// MatchAll returns the rule determining the ownership of the path in each section, in order of
+// the rules. The owners of the path are those of every rule other than exclusions. Files without
+// sections have a single section, so MatchAll returns at most one rule.
+func (r Ruleset) MatchAll(path string) ([]*Rule, error) {
+	candidates := make([]int, len(r))
+	for i := range candidates {
+		candidates[i] = len(r) - 1 - i
 	}
-	return nil, nil
+	return effectiveRules(r, candidates, path, isSectioned(r))
 }
 
 func newRule() *Rule {
 	r := Rule{
-		Owners: make([]string, 0),
+		Owners: make([]Owner, 0),
 	}
 	return &r
 }
@@ -104,6 +161,11 @@ func NewFileTree(rules []Rule) *FileTree {
 
 type FsStat func(string) (os.FileInfo, error)
 
+// ConsolidateTree removes rules whose owners are the same as those of a parent directory rule,
+// using os.Stat, or the given FsStat, to tell directories from files.
+//
+// Deprecated: ConsolidateTree depends on the working directory and only compares rules with the
+// same prefix. Use RedundantRules, which works from a list of files.
 func ConsolidateTree(tree *FileTree, mockableFsStat ...FsStat) {
 	var OsStat FsStat
 
@@ -113,7 +175,7 @@ func ConsolidateTree(tree *FileTree, mockableFsStat ...FsStat) {
 		OsStat = mockableFsStat[0]
 	}
 
-	sameSliceContents := func(s1, s2 []string) bool {
+	sameSliceContents := func(s1, s2 []Owner) bool {
 		if len(s1) != len(s2) {
 			return false
 		}
@@ -237,7 +237,7 @@ func TestConsolidateSubTree(t *testing.T) {
 					r.pattern = pat
 
 					for _, owner := range owners {
-						r.Owners = append(r.Owners, owner)
+						r.Owners = append(r.Owners, Owner{Value: owner, Type: UsernameOwner})
 					}
 
 					rules = append(rules, *r)
@@ -254,7 +254,7 @@ func TestConsolidateSubTree(t *testing.T) {
 				consolidatedRules = make(map[string][]string)
 				for pat, rule := range tree.rules {
 					for _, o := range rule.Owners {
-						consolidatedRules[pat] = append(consolidatedRules[pat], o)
+						consolidatedRules[pat] = append(consolidatedRules[pat], o.Value)
 					}
 				}
 			}
@@ -1,15 +1,20 @@
 package codeowners
 
 import (
+	"context"
+	"runtime"
 	"sort"
+	"sync"
 )
 
-type r struct {
-	Path   string   `json:"path"`
-	Owners []string `json:"owners"`
+// FileOwners is the list of owners of a single file. Unowned files belong to the Unowned group.
+type FileOwners struct {
+	Path   string  `json:"path"`
+	Owners []Owner `json:"owners"`
 }
 
-type Owners []*r
+// Owners is the list of files and their owners returned by ListOwners.
+type Owners []*FileOwners
 
 func (x Owners) Len() int           { return len(x) }
 func (x Owners) Less(a, b int) bool { return x[a].Path < x[b].Path }
@@ -19,41 +24,175 @@ func (x Owners) Swap(a, b int) {
 
 var _ sort.Interface = (Owners)(nil)
 
+// ListOptions controls which files ListOwnersContext reports, and how much work it does at once.
+type ListOptions struct {
+	// OwnerFilters restricts output to files owned by any of the given owners, each written as in
+	// CODEOWNERS or as the owner's Value.
+	OwnerFilters []string
+	// OwnerTypes restricts output to owners of the given types, such as TeamOwner.
+	OwnerTypes []string
+	// ShowUnowned includes unowned files even when OwnerFilters is set.
+	ShowUnowned bool
+	// Workers is the number of files checked concurrently. Zero means one per CPU.
+	Workers int
+}
+
+// listChunkSize is the number of files handed to a worker at a time.
+const listChunkSize = 256
+
 // ListOwners returns a list of structured output. Callers must format for printing.
 func ListOwners(rules Ruleset, files []string, ownerFilters []string, showUnowned bool) (Owners, error) {
-	var out []*r = make([]*r, 0)
+	return ListOwnersContext(context.Background(), rules, files, ListOptions{
+		OwnerFilters: ownerFilters,
+		ShowUnowned:  showUnowned,
+	})
+}
 
-	for _, file := range files {
-		rule, err := rules.Match(file)
-		if err != nil {
-			return nil, err
-		}
+// ListOwnersContext is like ListOwners, but checks files concurrently. Output is in the same order
+// as the input files regardless of the number of workers. It stops at the first error, or when the
+// context is cancelled, returning the error and no results.
+func ListOwnersContext(ctx context.Context, rules Ruleset, files []string, opts ListOptions) (Owners, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
 
-		if rule == nil || rule.Owners == nil || len(rule.Owners) == 0 {
-			if len(ownerFilters) == 0 || showUnowned {
-				out = append(out, &r{Path: file, Owners: []string{"(unowned)"}})
-			}
+	matcher := NewMatcher(rules)
+	results := make([]*FileOwners, len(files))
 
-			continue
-		}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
 
-		owners := make([]string, 0, len(rule.Owners))
-		for _, owner := range rule.Owners {
-			filterMatch := len(ownerFilters) == 0 && !showUnowned
-			for _, filter := range ownerFilters {
-				if filter == owner { // TODO: This is "Value" in hmarr. Are we losing info?
-					filterMatch = true
+	chunks := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				end := start + listChunkSize
+				if end > len(files) {
+					end = len(files)
+				}
+
+				for i := start; i < end; i++ {
+					if ctx.Err() != nil {
+						return
+					}
+
+					result, err := listFile(matcher, files[i], opts)
+					if err != nil {
+						fail(err)
+						return
+					}
+					results[i] = result
 				}
 			}
-			if filterMatch {
-				owners = append(owners, owner)
-			}
+		}()
+	}
+
+feed:
+	for start := 0; start < len(files); start += listChunkSize {
+		select {
+		case chunks <- start:
+		case <-ctx.Done():
+			break feed
 		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-		if len(owners) > 0 {
-			out = append(out, &r{Path: file, Owners: owners})
+	out := make(Owners, 0, len(results))
+	for _, result := range results {
+		if result != nil {
+			out = append(out, result)
 		}
 	}
 
 	return out, nil
 }
+
+// listFile returns the owners of a single file, or nil if the file is excluded by the filters.
+func listFile(matcher *Matcher, file string, opts ListOptions) (*FileOwners, error) {
+	rules, err := matcher.MatchAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// The owners of every section, except those excluding the file
+	var ruleOwners []Owner
+	for _, rule := range rules {
+		switch {
+		case rule.Negated:
+		case ruleOwners == nil:
+			ruleOwners = rule.Owners
+		default:
+			ruleOwners = unionOwners(ruleOwners, rule.Owners)
+		}
+	}
+
+	filtered := len(opts.OwnerFilters) > 0 || len(opts.OwnerTypes) > 0
+
+	if len(ruleOwners) == 0 {
+		if !filtered || opts.ShowUnowned {
+			return &FileOwners{Path: file, Owners: []Owner{Unowned}}, nil
+		}
+
+		return nil, nil
+	}
+
+	if opts.ShowUnowned && !filtered {
+		return nil, nil
+	}
+
+	owners := make([]Owner, 0, len(ruleOwners))
+	for _, owner := range ruleOwners {
+		if opts.matchOwner(owner) {
+			owners = append(owners, owner)
+		}
+	}
+
+	if len(owners) > 0 {
+		return &FileOwners{Path: file, Owners: owners}, nil
+	}
+
+	return nil, nil
+}
+
+// matchOwner reports whether the owner passes the owner and owner type filters.
+func (opts ListOptions) matchOwner(owner Owner) bool {
+	if len(opts.OwnerTypes) > 0 && !containsString(opts.OwnerTypes, owner.Type) {
+		return false
+	}
+	if len(opts.OwnerFilters) == 0 {
+		return true
+	}
+	return containsString(opts.OwnerFilters, owner.String()) || containsString(opts.OwnerFilters, owner.Value)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package codeowners
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListOwners(t *testing.T) {
+	rules, err := ParseFile(strings.NewReader(`* @default
+/docs/ @docs
+*.go @gophers
+/vendor/
+`))
+	require.NoError(t, err)
+
+	files := []string{"main.go", "docs/index.md", "README.md", "vendor/lib.go", "docs/gen.go"}
+
+	tests := []struct {
+		name        string
+		filters     []string
+		showUnowned bool
+		expected    Owners
+	}{
+		{
+			name: "no filters",
+			expected: Owners{
+				{Path: "main.go", Owners: mustOwners(t, "@gophers")},
+				{Path: "docs/index.md", Owners: mustOwners(t, "@docs")},
+				{Path: "README.md", Owners: mustOwners(t, "@default")},
+				{Path: "vendor/lib.go", Owners: []Owner{Unowned}},
+				{Path: "docs/gen.go", Owners: mustOwners(t, "@gophers")},
+			},
+		},
+		{
+			name:    "owner filter",
+			filters: []string{"@gophers"},
+			expected: Owners{
+				{Path: "main.go", Owners: mustOwners(t, "@gophers")},
+				{Path: "docs/gen.go", Owners: mustOwners(t, "@gophers")},
+			},
+		},
+		{
+			name:        "owner filter with unowned",
+			filters:     []string{"@docs"},
+			showUnowned: true,
+			expected: Owners{
+				{Path: "docs/index.md", Owners: mustOwners(t, "@docs")},
+				{Path: "vendor/lib.go", Owners: []Owner{Unowned}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ListOwners(rules, files, tt.filters, tt.showUnowned)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestListOwnersOwnerTypes(t *testing.T) {
+	rules := mustParse(t, `* @org/default
+*.go @gopher @org/go gopher@example.com
+/vendor/
+`)
+	files := []string{"main.go", "README.md", "vendor/lib.go"}
+
+	actual, err := ListOwnersContext(context.Background(), rules, files, ListOptions{OwnerTypes: []string{TeamOwner}})
+	require.NoError(t, err)
+	assert.Equal(t, Owners{
+		{Path: "main.go", Owners: mustOwners(t, "@org/go")},
+		{Path: "README.md", Owners: mustOwners(t, "@org/default")},
+	}, actual)
+
+	actual, err = ListOwnersContext(context.Background(), rules, files, ListOptions{
+		OwnerFilters: []string{"org/go", "gopher@example.com"},
+		OwnerTypes:   []string{TeamOwner, EmailOwner},
+		ShowUnowned:  true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Owners{
+		{Path: "main.go", Owners: mustOwners(t, "@org/go", "gopher@example.com")},
+		{Path: "vendor/lib.go", Owners: []Owner{Unowned}},
+	}, actual)
+}
+
+func TestListOwnersContextPreservesOrder(t *testing.T) {
+	rules, files := largeRuleset(t, 300, 5000)
+
+	serial, err := ListOwnersContext(context.Background(), rules, files, ListOptions{Workers: 1})
+	require.NoError(t, err)
+	require.Len(t, serial, len(files))
+
+	for _, workers := range []int{0, 2, 7, 32} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			parallel, err := ListOwnersContext(context.Background(), rules, files, ListOptions{Workers: workers})
+			require.NoError(t, err)
+			assert.Equal(t, serial, parallel)
+		})
+	}
+}
+
+func TestListOwnersContextCancelled(t *testing.T) {
+	rules, files := largeRuleset(t, 300, 5000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	out, err := ListOwnersContext(ctx, rules, files, ListOptions{Workers: 4})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, out)
+}
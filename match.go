@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type pattern struct {
@@ -13,14 +15,29 @@ type pattern struct {
 	leftAnchoredLiteral bool
 }
 
-// newPattern creates a new pattern struct from a gitignore-style pattern string
+// newPattern creates a new pattern struct from a gitignore-style pattern string, as GitHub reads
+// it: brackets are literal.
 func newPattern(patternStr string) (pattern, error) {
+	return compilePattern(patternStr, false)
+}
+
+// newGitignorePattern creates a new pattern struct from a pattern string with the full gitignore
+// syntax, including character classes such as [a-z] and [!0-9].
+func newGitignorePattern(patternStr string) (pattern, error) {
+	return compilePattern(patternStr, true)
+}
+
+func compilePattern(patternStr string, classes bool) (pattern, error) {
 	pat := pattern{pattern: patternStr}
 
-	if !strings.ContainsAny(patternStr, "*?\\") && patternStr[0] == '/' {
+	specials := "*?\\"
+	if classes {
+		specials += "["
+	}
+	if !strings.ContainsAny(patternStr, specials) && patternStr[0] == '/' {
 		pat.leftAnchoredLiteral = true
 	} else {
-		patternRegex, err := buildPatternRegex(patternStr)
+		patternRegex, err := buildPatternRegex(patternStr, classes)
 		if err != nil {
 			return pattern{}, err
 		}
@@ -65,8 +82,9 @@ func (p pattern) match(testPath string) (bool, error) {
 	return p.regex.MatchString(testPath), nil
 }
 
-// buildPatternRegex compiles a new regexp object from a gitignore-style pattern string
-func buildPatternRegex(pattern string) (*regexp.Regexp, error) {
+// buildPatternRegex compiles a new regexp object from a gitignore-style pattern string, with
+// character classes if classes is set
+func buildPatternRegex(pattern string, classes bool) (*regexp.Regexp, error) {
 	// Handle specific edge cases first
 	switch {
 	case strings.Contains(pattern, "***"):
@@ -139,18 +157,36 @@ func buildPatternRegex(pattern string) (*regexp.Regexp, error) {
 			}
 
 			escape := false
-			for _, ch := range seg {
+			runes := []rune(seg)
+			for j := 0; j < len(runes); j++ {
+				ch := runes[j]
 				if escape {
 					escape = false
 					re.WriteString(regexp.QuoteMeta(string(ch)))
 					continue
 				}
 
-				// Other pathspec implementations handle character classes here (e.g.
-				// [AaBb]), but CODEOWNERS doesn't support that so we don't need to
 				switch ch {
 				case '\\':
 					escape = true
+				case '[':
+					if !classes {
+						re.WriteString(regexp.QuoteMeta(string(ch)))
+						continue
+					}
+
+					// Character classes (e.g. [AaBb]), which GitHub doesn't support. Unclosed
+					// brackets are literal.
+					class, end, err := characterClass(runes, j, false)
+					if err != nil {
+						return nil, err
+					}
+					if end < 0 {
+						re.WriteString(regexp.QuoteMeta(string(ch)))
+						continue
+					}
+					re.WriteString(class)
+					j = end
 				case '*':
 					// Multi-character wildcard
 					re.WriteString(`[^` + sep + `]*`)
@@ -175,3 +211,125 @@ func buildPatternRegex(pattern string) (*regexp.Regexp, error) {
 	re.WriteString(`\z`)
 	return regexp.Compile(re.String())
 }
+
+// posixClasses are the names of the character classes allowed in brackets, such as [[:digit:]].
+var posixClasses = map[string]bool{
+	"alnum": true, "alpha": true, "blank": true, "cntrl": true, "digit": true, "graph": true,
+	"lower": true, "print": true, "punct": true, "space": true, "upper": true, "xdigit": true,
+}
+
+// characterClass translates the gitignore character class starting at runes[start], such as [a-z]
+// or [!0-9], to a regular expression, returning the index of its closing bracket, or -1 if it isn't
+// closed. Negated classes only match the separator if matchSeparator is set.
+func characterClass(runes []rune, start int, matchSeparator bool) (string, int, error) {
+	var re strings.Builder
+	re.WriteString("[")
+
+	i := start + 1
+	if i < len(runes) && (runes[i] == '!' || runes[i] == '^') {
+		re.WriteString("^")
+		if !matchSeparator {
+			re.WriteString("/")
+		}
+		i++
+	}
+
+	for first := true; i < len(runes); i, first = i+1, false {
+		ch := runes[i]
+		switch {
+		case ch == ']' && !first:
+			re.WriteString("]")
+			return re.String(), i, nil
+
+		case ch == '[' && i+1 < len(runes) && runes[i+1] == ':':
+			if end := strings.Index(string(runes[i+2:]), ":]"); end >= 0 {
+				name := string(runes[i+2:])[:end]
+				if !posixClasses[name] {
+					return "", 0, fmt.Errorf("unknown character class [:%s:]", name)
+				}
+				re.WriteString("[:" + name + ":]")
+				i += 2 + len([]rune(name)) + 1
+				continue
+			}
+		}
+
+		lo := ch
+		if lo == '\\' && i+1 < len(runes) {
+			i++
+			lo = runes[i]
+		}
+		if i+2 < len(runes) && runes[i+1] == '-' && runes[i+2] != ']' {
+			i += 2
+			hi := runes[i]
+			if hi == '\\' && i+1 < len(runes) {
+				i++
+				hi = runes[i]
+			}
+			if hi < lo {
+				return "", 0, fmt.Errorf("invalid character range %c-%c", lo, hi)
+			}
+			re.WriteString(classChar(lo) + "-" + classChar(hi))
+			continue
+		}
+		re.WriteString(classChar(lo))
+	}
+
+	return "", -1, nil
+}
+
+// classChar escapes a character for use in a regular expression character class.
+func classChar(ch rune) string {
+	if ch < utf8.RuneSelf && (unicode.IsPunct(ch) || unicode.IsSymbol(ch)) {
+		return `\` + string(ch)
+	}
+	return string(ch)
+}
+
+// PathspecMatcher returns a function matching paths against a git-style pathspec, both relative to
+// the repository root. A literal path matches itself and everything beneath it, and "." matches
+// every path. Globs match like fnmatch without FNM_PATHNAME, so wildcards and character classes
+// also match across directories.
+func PathspecMatcher(spec string) (func(string) bool, error) {
+	if !strings.ContainsAny(spec, `*?[\`) {
+		return func(path string) bool {
+			return spec == "." || path == spec || strings.HasPrefix(path, spec+"/")
+		}, nil
+	}
+
+	var re strings.Builder
+	re.WriteString(`\A`)
+	runes := []rune(spec)
+	for i := 0; i < len(runes); i++ {
+		switch ch := runes[i]; ch {
+		case '*':
+			re.WriteString(`.*`)
+		case '?':
+			re.WriteString(`.`)
+		case '[':
+			class, end, err := characterClass(runes, i, true)
+			if err != nil {
+				return nil, err
+			}
+			if end < 0 {
+				re.WriteString(regexp.QuoteMeta(string(ch)))
+				continue
+			}
+			re.WriteString(class)
+			i = end
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(string(runes[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	re.WriteString(`(?:/.*)?\z`)
+
+	pattern, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, err
+	}
+	return pattern.MatchString, nil
+}
@@ -14,6 +14,8 @@ type patternTest struct {
 	Pattern string          `json:"pattern"`
 	Paths   map[string]bool `json:"paths"`
 	Focus   bool            `json:"focus"`
+	// Gitignore is set for patterns using gitignore syntax that GitHub doesn't support
+	Gitignore bool `json:"gitignore"`
 }
 
 func TestMatch(t *testing.T) {
@@ -38,7 +40,11 @@ func TestMatch(t *testing.T) {
 
 		t.Run(test.Name, func(t *testing.T) {
 			for path, shouldMatch := range test.Paths {
-				pattern, err := newPattern(test.Pattern)
+				compile := newPattern
+				if test.Gitignore {
+					compile = newGitignorePattern
+				}
+				pattern, err := compile(test.Pattern)
 				require.NoError(t, err)
 
 				// Debugging tips:
@@ -57,3 +63,40 @@ func TestMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestPathspecMatcher(t *testing.T) {
+	tests := []struct {
+		spec  string
+		paths map[string]bool
+	}{
+		{".", map[string]bool{"a.go": true, "src/a.go": true}},
+		{"src", map[string]bool{"src": true, "src/a.go": true, "src2/a.go": false}},
+		{"*.go", map[string]bool{"a.go": true, "src/lib/a.go": true, "a.md": false}},
+		{"src/?.go", map[string]bool{"src/a.go": true, "src/ab.go": false}},
+		{"src/[abc].go", map[string]bool{"src/a.go": true, "src/c.go": true, "src/d.go": false}},
+		{"src/[!a].go", map[string]bool{"src/a.go": false, "src/b.go": true}},
+		{"src[!a]a.go", map[string]bool{"src/a.go": true}},
+		{"src/[]].go", map[string]bool{"src/].go": true, "src/a.go": false}},
+		{"src/[!]].go", map[string]bool{"src/].go": false, "src/a.go": true}},
+		{"src/[a-c].go", map[string]bool{"src/b.go": true, "src/d.go": false}},
+		{`src/[a-\w].go`, map[string]bool{"src/m.go": true, "src/x.go": false}},
+		{`src/[\]].go`, map[string]bool{"src/].go": true, "src/\\.go": false}},
+		{`src/[\-].go`, map[string]bool{"src/-.go": true, "src/a.go": false}},
+		{`src/[.^$].go`, map[string]bool{"src/^.go": true, "src/a.go": false}},
+		{"src/[[:digit:]].go", map[string]bool{"src/1.go": true, "src/a.go": false}},
+		{"src/[ab", map[string]bool{"src/[ab": true, "src/a": false}},
+		{`\*.go`, map[string]bool{"*.go": true, "a.go": false}},
+		{"ソース/[ソ]*", map[string]bool{"ソース/ソ.go": true, "ソース/a.go": false}},
+	}
+
+	for _, test := range tests {
+		match, err := PathspecMatcher(test.spec)
+		require.NoError(t, err, test.spec)
+		for path, expected := range test.paths {
+			assert.Equal(t, expected, match(path), "%s %s", test.spec, path)
+		}
+	}
+
+	_, err := PathspecMatcher("src/[z-a].go")
+	assert.EqualError(t, err, "invalid character range z-a")
+}
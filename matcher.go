@@ -0,0 +1,283 @@
+package codeowners
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Matcher is a compiled form of a Ruleset for matching many paths against many rules. It returns
+// exactly the same results as Ruleset.Match, but rather than testing every rule in turn it uses
+// an index to select the few rules that could possibly match a path:
+//
+//   - anchored patterns are stored in a trie keyed by their leading literal segments, so /src/app/
+//     and src/app/**/*.ts are only tested against paths under src/app;
+//   - patterns ending in a literal name (e.g. docs/*/README.md) are keyed by that name;
+//   - globs ending in a literal extension (e.g. *.go) are keyed by the extension.
+//
+// The remaining rules are tested against every path. Candidates are verified with Rule.Match, in
+// reverse order, so last-match-wins semantics are preserved. A Matcher is safe for concurrent use.
+type Matcher struct {
+	rules     Ruleset
+	prefix    *trieNode
+	names     map[string][]int
+	exts      map[string][]int
+	generic   []int
+	sectioned bool
+}
+
+type trieNode struct {
+	children map[string]*trieNode
+	rules    []int
+}
+
+// NewMatcher compiles the ruleset into a Matcher. The ruleset must not be modified while the
+// Matcher is in use.
+func NewMatcher(rules Ruleset) *Matcher {
+	m := &Matcher{
+		rules:  rules,
+		prefix: &trieNode{},
+		names:  make(map[string][]int),
+		exts:   make(map[string][]int),
+	}
+	m.sectioned = isSectioned(rules)
+
+	for i := range rules {
+		m.add(i, rules[i].pattern)
+	}
+
+	return m
+}
+
+func (m *Matcher) add(i int, p pattern) {
+	prefix := literalPrefix(p)
+	name, hasName := trailingLiteralName(p.pattern)
+	ext, hasExt := trailingLiteralExtension(p.pattern)
+
+	// Prefer the most selective index for each rule.
+	switch {
+	case len(prefix) > 1:
+		m.insertPrefix(prefix, i)
+	case hasName:
+		m.names[name] = append(m.names[name], i)
+	case hasExt:
+		m.exts[ext] = append(m.exts[ext], i)
+	case len(prefix) == 1:
+		m.insertPrefix(prefix, i)
+	default:
+		m.generic = append(m.generic, i)
+	}
+}
+
+func (m *Matcher) insertPrefix(segs []string, i int) {
+	node := m.prefix
+	for _, seg := range segs {
+		child, ok := node.children[seg]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[string]*trieNode)
+			}
+			child = &trieNode{}
+			node.children[seg] = child
+		}
+		node = child
+	}
+	node.rules = append(node.rules, i)
+}
+
+// Match finds the last rule in the ruleset that matches the path provided, exactly as
+// Ruleset.Match does.
+func (m *Matcher) Match(path string) (*Rule, error) {
+	rules, err := m.MatchAll(path)
+	return decisiveRule(rules), err
+}
+
+// MatchAll returns the rule determining the ownership of the path in each section, exactly as
+// Ruleset.MatchAll does.
+func (m *Matcher) MatchAll(path string) ([]*Rule, error) {
+	path = filepath.ToSlash(path)
+	return effectiveRules(m.rules, m.candidates(path), path, m.sectioned)
+}
+
+// isSectioned reports whether the rules have exclusions or sections, which make matching consider
+// more than the last matching rule.
+func isSectioned(rules Ruleset) bool {
+	for i := range rules {
+		if rules[i].Negated || rules[i].Section != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveRules returns the rule determining the ownership of path in each section, given the
+// indexes of the candidate rules in descending order. Within a section the last matching rule
+// wins, unless an exclusion matches, wherever it is.
+func effectiveRules(rules Ruleset, candidates []int, path string, sectioned bool) ([]*Rule, error) {
+	if !sectioned {
+		for _, i := range candidates {
+			rule := &rules[i]
+			match, err := rule.Match(path)
+			if match || err != nil {
+				return []*Rule{rule}, err
+			}
+		}
+		return nil, nil
+	}
+
+	// The matching rule and exclusion with the highest index in each section
+	matched := make(map[string]int)
+	excluded := make(map[string]int)
+	for _, i := range candidates {
+		rule := &rules[i]
+		if _, ok := excluded[rule.Section]; ok {
+			continue
+		}
+		if _, ok := matched[rule.Section]; ok && !rule.Negated {
+			continue
+		}
+
+		match, err := rule.Match(path)
+		if err != nil {
+			return []*Rule{rule}, err
+		}
+		if !match {
+			continue
+		}
+		if rule.Negated {
+			excluded[rule.Section] = i
+		} else {
+			matched[rule.Section] = i
+		}
+	}
+
+	for section, i := range excluded {
+		matched[section] = i
+	}
+	indexes := make([]int, 0, len(matched))
+	for _, i := range matched {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	out := make([]*Rule, len(indexes))
+	for j, i := range indexes {
+		out[j] = &rules[i]
+	}
+	return out, nil
+}
+
+// decisiveRule returns the last of the rules other than exclusions, or the last exclusion if all
+// of them are.
+func decisiveRule(rules []*Rule) *Rule {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if !rules[i].Negated {
+			return rules[i]
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return rules[len(rules)-1]
+}
+
+// candidates returns the indexes of all rules that could match the path, in descending order.
+func (m *Matcher) candidates(path string) []int {
+	segs := strings.Split(path, "/")
+
+	out := make([]int, 0, len(m.generic)+8)
+	out = append(out, m.generic...)
+
+	node := m.prefix
+	for _, seg := range segs {
+		child, ok := node.children[seg]
+		if !ok {
+			break
+		}
+		out = append(out, child.rules...)
+		node = child
+	}
+
+	seenName := make(map[string]bool, len(segs))
+	seenExt := make(map[string]bool, len(segs))
+	for _, seg := range segs {
+		if !seenName[seg] {
+			seenName[seg] = true
+			out = append(out, m.names[seg]...)
+		}
+
+		dot := strings.LastIndexByte(seg, '.')
+		if dot < 0 {
+			continue
+		}
+		if ext := seg[dot+1:]; !seenExt[ext] {
+			seenExt[ext] = true
+			out = append(out, m.exts[ext]...)
+		}
+	}
+
+	// Each rule lives in exactly one bucket, so there are no duplicates to remove.
+	sort.Sort(sort.Reverse(sort.IntSlice(out)))
+	return out
+}
+
+// hasWildcard reports whether a pattern segment contains any character with special meaning.
+func hasWildcard(seg string) bool {
+	return strings.ContainsAny(seg, "*?\\[")
+}
+
+// literalPrefix returns the leading literal segments of an anchored pattern. Any path matched by
+// the pattern must begin with these segments.
+func literalPrefix(p pattern) []string {
+	segs := strings.Split(p.pattern, "/")
+
+	switch {
+	case segs[0] == "":
+		// Leading slash: anchored to the root
+		segs = segs[1:]
+	case len(segs) == 1 || (len(segs) == 2 && segs[1] == ""):
+		// Single segment patterns match at any depth
+		return nil
+	}
+
+	var prefix []string
+	for _, seg := range segs {
+		if seg == "" || (!p.leftAnchoredLiteral && hasWildcard(seg)) {
+			break
+		}
+		prefix = append(prefix, seg)
+	}
+	return prefix
+}
+
+// trailingLiteralName returns the last segment of a pattern, if it is a literal. Any path matched by
+// such a pattern must contain a segment with that name.
+func trailingLiteralName(pat string) (string, bool) {
+	segs := strings.Split(strings.TrimRight(pat, "/"), "/")
+	last := segs[len(segs)-1]
+
+	if last == "" || hasWildcard(last) {
+		return "", false
+	}
+	return last, true
+}
+
+// trailingLiteralExtension returns the extension of a pattern whose last segment is a glob ending in
+// a literal extension (e.g. "*.go" or "foo_*.test.ts"). Any path matched by such a pattern must
+// contain a segment with that extension.
+func trailingLiteralExtension(pat string) (string, bool) {
+	segs := strings.Split(strings.TrimRight(pat, "/"), "/")
+	last := segs[len(segs)-1]
+
+	star := strings.LastIndexByte(last, '*')
+	if star < 0 || strings.ContainsAny(last, "?\\[") {
+		return "", false
+	}
+
+	tail := last[star+1:]
+	dot := strings.LastIndexByte(tail, '.')
+	if dot < 0 || dot == len(tail)-1 {
+		return "", false
+	}
+	return tail[dot+1:], true
+}
@@ -0,0 +1,169 @@
+package codeowners
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadPatternCorpus returns every pattern in testdata/patterns.json with GitHub or gitignore
+// syntax, and every path.
+func loadPatternCorpus(t testing.TB, gitignore bool) ([]string, []string) {
+	data, err := ioutil.ReadFile("testdata/patterns.json")
+	require.NoError(t, err)
+
+	var tests []patternTest
+	require.NoError(t, json.Unmarshal(data, &tests))
+
+	var patterns []string
+	paths := make(map[string]bool)
+	for _, test := range tests {
+		if test.Gitignore == gitignore {
+			patterns = append(patterns, test.Pattern)
+		}
+		for path := range test.Paths {
+			paths[path] = true
+		}
+	}
+
+	var sorted []string
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	return patterns, sorted
+}
+
+func buildRuleset(t testing.TB, patterns []string) Ruleset {
+	return buildRulesetWith(t, newPattern, patterns)
+}
+
+func buildRulesetWith(t testing.TB, compile func(string) (pattern, error), patterns []string) Ruleset {
+	rules := make(Ruleset, 0, len(patterns))
+	for i, pat := range patterns {
+		p, err := compile(pat)
+		require.NoError(t, err)
+		rules = append(rules, Rule{SourceLine: i + 1, pattern: p, Owners: []Owner{{Value: fmt.Sprintf("owner%d", i), Type: UsernameOwner}}})
+	}
+	return rules
+}
+
+func assertEquivalent(t *testing.T, rules Ruleset, paths []string) {
+	matcher := NewMatcher(rules)
+	for _, path := range paths {
+		expected, err := rules.Match(path)
+		require.NoError(t, err)
+
+		actual, err := matcher.Match(path)
+		require.NoError(t, err)
+
+		assert.Same(t, expected, actual, "path %s", path)
+	}
+}
+
+func TestMatcherEquivalence(t *testing.T) {
+	patterns, paths := loadPatternCorpus(t, false)
+
+	t.Run("each pattern", func(t *testing.T) {
+		for _, pat := range patterns {
+			assertEquivalent(t, buildRuleset(t, []string{pat}), paths)
+		}
+	})
+
+	t.Run("all patterns", func(t *testing.T) {
+		assertEquivalent(t, buildRuleset(t, patterns), paths)
+	})
+
+	t.Run("all patterns reversed", func(t *testing.T) {
+		reversed := make([]string, len(patterns))
+		for i, pat := range patterns {
+			reversed[len(patterns)-1-i] = pat
+		}
+		assertEquivalent(t, buildRuleset(t, reversed), paths)
+	})
+
+	t.Run("gitignore patterns", func(t *testing.T) {
+		gitignore, _ := loadPatternCorpus(t, true)
+		for _, pat := range gitignore {
+			assertEquivalent(t, buildRulesetWith(t, newGitignorePattern, []string{pat}), paths)
+		}
+		assertEquivalent(t, buildRulesetWith(t, newGitignorePattern, gitignore), paths)
+	})
+
+	t.Run("large ruleset", func(t *testing.T) {
+		rules, files := largeRuleset(t, 500, 2000)
+		assertEquivalent(t, rules, append(files, paths...))
+	})
+}
+
+// largeRuleset generates a ruleset resembling a large monorepo, along with a list of files.
+func largeRuleset(t testing.TB, numRules, numFiles int) (Ruleset, []string) {
+	patterns := []string{"*"}
+	for i := 0; len(patterns) < numRules; i++ {
+		switch i % 6 {
+		case 0:
+			patterns = append(patterns, fmt.Sprintf("/services/svc%d/", i))
+		case 1:
+			patterns = append(patterns, fmt.Sprintf("/libs/lib%d", i))
+		case 2:
+			patterns = append(patterns, fmt.Sprintf("services/svc%d/**/*.proto", i-2))
+		case 3:
+			patterns = append(patterns, fmt.Sprintf("*.ext%d", i))
+		case 4:
+			patterns = append(patterns, fmt.Sprintf("/services/svc%d/cmd/main.go", i-4))
+		case 5:
+			patterns = append(patterns, fmt.Sprintf("docs/*/page%d.md", i))
+		}
+	}
+
+	var files []string
+	for i := 0; len(files) < numFiles; i++ {
+		switch i % 5 {
+		case 0:
+			files = append(files, fmt.Sprintf("services/svc%d/cmd/main.go", (i/5)*6%numRules))
+		case 1:
+			files = append(files, fmt.Sprintf("services/svc%d/api/v1/service.proto", (i/5)*6%numRules))
+		case 2:
+			files = append(files, fmt.Sprintf("libs/lib%d/src/file.ext%d", (i/5)*6%numRules+1, (i/5)*6%numRules+3))
+		case 3:
+			files = append(files, fmt.Sprintf("docs/guide/page%d.md", (i/5)*6%numRules+5))
+		case 4:
+			files = append(files, fmt.Sprintf("README%d.md", i))
+		}
+	}
+
+	return buildRuleset(t, patterns), files
+}
+
+func BenchmarkRulesetMatch(b *testing.B) {
+	rules, files := largeRuleset(b, 3000, 1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, file := range files {
+			if _, err := rules.Match(file); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMatcherMatch(b *testing.B) {
+	rules, files := largeRuleset(b, 3000, 1000)
+	matcher := NewMatcher(rules)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, file := range files {
+			if _, err := matcher.Match(file); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
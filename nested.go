@@ -0,0 +1,354 @@
+package codeowners
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// OwnersFile is the ownership declared by a per-directory OWNERS file.
+type OwnersFile struct {
+	// Owners own every file in the directory and beneath it, along with the owners of the parent
+	// directories unless NoParent is set.
+	Owners   []Owner
+	NoParent bool
+	// Anyone is set when anyone may approve changes, which CODEOWNERS writes as a rule without
+	// owners.
+	Anyone bool
+	// Line is the line of the first owner or "set noparent" declaration.
+	Line int
+	// Rules give the files matching their patterns more owners.
+	Rules []OwnersRule
+	// Includes are other OWNERS files whose owners, and optionally rules, are added to this one.
+	Includes []OwnersInclude
+	// Warnings describe declarations that rules can't express exactly, such as "line 3: ...".
+	Warnings []string
+}
+
+// OwnersInclude refers to another OWNERS file, by a path relative to the directory of the file, or
+// to the repository root if it starts with "//".
+type OwnersInclude struct {
+	Path string
+	Line int
+	// Rules also adds the rules of the included file.
+	Rules bool
+}
+
+// OwnersRule gives the files matching a pattern, relative to the directory of its OWNERS file,
+// owners in addition to those of the directory, or instead of them with NoParent.
+type OwnersRule struct {
+	Pattern  string
+	Owners   []Owner
+	NoParent bool
+	Anyone   bool
+	Line     int
+	// Includes are other OWNERS files whose owners are added to the rule's.
+	Includes []string
+}
+
+// OwnersParser parses the OWNERS file of a directory. Dialects such as Kubernetes and Chromium
+// OWNERS files are supported by their own parsers.
+type OwnersParser func(f io.Reader) (*OwnersFile, error)
+
+// NestedOptions controls how LoadNestedFS finds and parses OWNERS files.
+type NestedOptions struct {
+	// Names are the file names of OWNERS files. The default is OWNERS.
+	Names []string
+	// Parse parses each file. The default is ParseOwnersFile.
+	Parse OwnersParser
+	// Files lists the candidate files, such as the files tracked by git. By default, every file in
+	// fsys is considered.
+	Files []string
+	// Warn, if set, is called with the warnings of each file, prefixed by its name.
+	Warn func(warning string)
+}
+
+// ParseOwnersFile parses an OWNERS file in the default format, which is the CODEOWNERS format with
+// patterns relative to the directory of the file, plus lines listing owners of the whole directory
+// and "set noparent":
+//
+//	# Owners of everything in this directory
+//	@org/payments alice@example.com
+//	set noparent
+//	# Also owned by @org/api
+//	*.proto @org/api
+func ParseOwnersFile(f io.Reader) (*OwnersFile, error) {
+	file := &OwnersFile{}
+	scanner := bufio.NewScanner(f)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 && (i == 0 || isWhitespace(rune(line[i-1]))) {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) == 2 && fields[0] == "set" && fields[1] == "noparent":
+			file.NoParent = true
+		case allOwners(fields):
+			for _, field := range fields {
+				owner, _ := newOwner(field)
+				file.Owners = append(file.Owners, owner)
+			}
+		default:
+			r := newRule()
+			if err := parseRule(line, r); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			file.Rules = append(file.Rules, OwnersRule{Pattern: r.RawPattern(), Owners: r.Owners, Line: lineNo})
+			continue
+		}
+
+		if file.Line == 0 {
+			file.Line = lineNo
+		}
+	}
+
+	return file, scanner.Err()
+}
+
+func allOwners(fields []string) bool {
+	for _, field := range fields {
+		if _, err := newOwner(field); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadNestedFS finds the OWNERS files in fsys and composes them into a single ruleset, in which
+// each rule's SourceFile and SourceLine give the OWNERS file and line it came from.
+//
+// The owners of a directory are those in its OWNERS file plus, unless it has "set noparent", the
+// owners of its parent directory. A pattern applies to matching files beneath the directory of its
+// OWNERS file, and its owners are added to those of the directory. Patterns don't apply beneath a
+// subdirectory with owners of its own, whose files are owned according to its OWNERS file. The
+// files an OWNERS file includes are read from fsys as well.
+func LoadNestedFS(fsys fs.FS, opts NestedOptions) (Ruleset, error) {
+	if len(opts.Names) == 0 {
+		opts.Names = []string{"OWNERS"}
+	}
+	if opts.Parse == nil {
+		opts.Parse = ParseOwnersFile
+	}
+
+	candidates := opts.Files
+	if candidates == nil {
+		var err error
+		if candidates, err = ListFilesFS(fsys); err != nil {
+			return nil, err
+		}
+	}
+
+	loader := &includeLoader{fsys: fsys, parse: opts.Parse, files: make(map[string]*OwnersFile)}
+
+	// OWNERS files by directory, and their names
+	files := make(map[string]*OwnersFile)
+	names := make(map[string]string)
+	for _, name := range candidates {
+		if !containsString(opts.Names, path.Base(name)) {
+			continue
+		}
+		dir := parentDir(name)
+		if _, ok := files[dir]; ok {
+			return nil, fmt.Errorf("%s: %s has more than one OWNERS file", name, dirName(dir))
+		}
+
+		file, err := loader.load(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Warn != nil {
+			for _, warning := range file.Warnings {
+				opts.Warn(name + ": " + warning)
+			}
+		}
+		files[dir] = file
+		names[dir] = name
+	}
+
+	return composeNested(files, names)
+}
+
+// includeLoader parses OWNERS files and the files they include, once each.
+type includeLoader struct {
+	fsys  fs.FS
+	parse OwnersParser
+	files map[string]*OwnersFile
+}
+
+// load returns the OWNERS file with the given name, with the owners and rules of the files it
+// includes added. stack lists the files including it, to detect cycles.
+func (l *includeLoader) load(name string, stack []string) (*OwnersFile, error) {
+	if containsString(stack, name) {
+		return nil, fmt.Errorf("%s: include cycle: %s", stack[0], strings.Join(append(stack, name), " -> "))
+	}
+	if file, ok := l.files[name]; ok {
+		return file, nil
+	}
+
+	f, err := l.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := l.parse(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	stack = append(stack, name)
+	included := func(p string, line int) (*OwnersFile, error) {
+		inc, err := l.load(includePath(name, p), stack)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s: line %d: included file %s doesn't exist", name, line, p)
+		}
+		return inc, err
+	}
+
+	for _, include := range file.Includes {
+		inc, err := included(include.Path, include.Line)
+		if err != nil {
+			return nil, err
+		}
+		file.Owners = unionOwners(file.Owners, inc.Owners)
+		file.Anyone = file.Anyone || inc.Anyone
+		if include.Rules {
+			for _, r := range inc.Rules {
+				r.Line = include.Line
+				file.Rules = append(file.Rules, r)
+			}
+		}
+	}
+	for i := range file.Rules {
+		r := &file.Rules[i]
+		for _, p := range r.Includes {
+			inc, err := included(p, r.Line)
+			if err != nil {
+				return nil, err
+			}
+			r.Owners = unionOwners(r.Owners, inc.Owners)
+			r.Anyone = r.Anyone || inc.Anyone
+		}
+		r.Includes = nil
+	}
+	file.Includes = nil
+
+	l.files[name] = file
+	return file, nil
+}
+
+// includePath returns the path of a file included by the OWNERS file name.
+func includePath(name, p string) string {
+	if strings.HasPrefix(p, "//") {
+		return path.Clean(strings.TrimPrefix(p, "//"))
+	}
+	return path.Join(path.Dir(name), p)
+}
+
+// composeNested returns the rules for the OWNERS files of each directory, parents first so that
+// the rules of subdirectories take precedence.
+func composeNested(files map[string]*OwnersFile, names map[string]string) (Ruleset, error) {
+	dirs := make([]string, 0, len(files))
+	for dir := range files {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	// The owners of each directory with an OWNERS file, including inherited ones, and whether
+	// anyone may approve changes to it
+	owners := make(map[string][]Owner)
+	anyone := make(map[string]bool)
+	inherited := func(dir string) ([]Owner, bool) {
+		for dir != "" {
+			dir = parentDir(dir)
+			if o, ok := owners[dir]; ok {
+				return o, anyone[dir]
+			}
+		}
+		return nil, false
+	}
+
+	rules := make(Ruleset, 0)
+	for _, dir := range dirs {
+		file := files[dir]
+
+		dirOwners, dirAnyone := file.Owners, file.Anyone
+		if !file.NoParent {
+			parentOwners, parentAnyone := inherited(dir)
+			dirOwners, dirAnyone = unionOwners(file.Owners, parentOwners), dirAnyone || parentAnyone
+		}
+		if dirAnyone {
+			dirOwners = nil
+		}
+		owners[dir], anyone[dir] = dirOwners, dirAnyone
+
+		// The directory's rule also stops the patterns of its ancestors applying beneath it
+		if len(dirOwners) > 0 || file.NoParent || dirAnyone {
+			pattern := "*"
+			if dir != "" {
+				pattern = suggestPattern(dir, true)
+			}
+			rule, err := NewRule(pattern, dirOwners...)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", names[dir], err)
+			}
+			rule.SourceFile, rule.SourceLine = names[dir], file.Line
+			rules = append(rules, rule)
+		}
+
+		for _, r := range file.Rules {
+			ruleOwners, ruleAnyone := r.Owners, r.Anyone
+			if !r.NoParent {
+				ruleOwners, ruleAnyone = unionOwners(r.Owners, dirOwners), ruleAnyone || dirAnyone
+			}
+			if ruleAnyone {
+				ruleOwners = nil
+			}
+			rule, err := NewRule(scopePattern(dir, r.Pattern), ruleOwners...)
+			if err != nil {
+				return nil, fmt.Errorf("%s: line %d: %v", names[dir], r.Line, err)
+			}
+			rule.SourceFile, rule.SourceLine = names[dir], r.Line
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// scopePattern rewrites a pattern relative to dir as a pattern relative to the repository root.
+// As in gitignore files, patterns with a slash other than a trailing one are anchored to dir, and
+// patterns without one match at any depth beneath it.
+func scopePattern(dir, pattern string) string {
+	if dir == "" {
+		return pattern
+	}
+	prefix := strings.TrimSuffix(suggestPattern(dir, true), "/")
+	if strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		return prefix + "/" + strings.TrimPrefix(pattern, "/")
+	}
+	return prefix + "/**/" + pattern
+}
+
+// unionOwners returns the owners in a followed by those in b that aren't in a.
+func unionOwners(a, b []Owner) []Owner {
+	out := make([]Owner, 0, len(a)+len(b))
+	out = append(out, a...)
+	return append(out, ownersDifference(b, a)...)
+}
+
+func dirName(dir string) string {
+	if dir == "" {
+		return "the root directory"
+	}
+	return dir
+}
@@ -0,0 +1,123 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nestedFS() fstest.MapFS {
+	return fstest.MapFS{
+		"OWNERS": {Data: []byte(`# Repository owners
+@org/core
+*.md @org/docs
+`)},
+		"services/payments/OWNERS": {Data: []byte(`@org/payments alice@example.com
+*.proto @org/api # API reviews
+/db/ @org/dba
+`)},
+		"services/payments/legacy/OWNERS": {Data: []byte(`set noparent
+@org/legacy
+`)},
+		"services/search/OWNERS": {Data: []byte(`# Patterns only
+index/ @org/search
+`)},
+		"README.md":                          {},
+		"go.mod":                             {},
+		"services/payments/api.go":           {},
+		"services/payments/api.proto":        {},
+		"services/payments/v1/users.proto":   {},
+		"services/payments/db/schema.sql":    {},
+		"services/payments/legacy/old.go":    {},
+		"services/payments/legacy/README.md": {},
+		"services/search/index/shard.go":     {},
+		"services/search/docs/design.md":     {},
+		"services/search/query.go":           {},
+	}
+}
+
+// ruleSources returns each rule prefixed with the file it came from.
+func ruleSources(rules []Rule) []string {
+	lines := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		lines = append(lines, rule.SourceFile+":"+strings.TrimSpace(rule.String()))
+	}
+	return lines
+}
+
+func TestLoadNestedFS(t *testing.T) {
+	rules, err := LoadNestedFS(nestedFS(), NestedOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"OWNERS:* @org/core",
+		"OWNERS:*.md @org/docs @org/core",
+		"services/payments/OWNERS:/services/payments/ @org/payments alice@example.com @org/core",
+		"services/payments/OWNERS:/services/payments/**/*.proto @org/api @org/payments alice@example.com @org/core",
+		"services/payments/OWNERS:/services/payments/db/ @org/dba @org/payments alice@example.com @org/core",
+		"services/payments/legacy/OWNERS:/services/payments/legacy/ @org/legacy",
+		"services/search/OWNERS:/services/search/ @org/core",
+		"services/search/OWNERS:/services/search/**/index/ @org/search @org/core",
+	}, ruleSources(rules))
+	assert.Equal(t, 2, rules[3].SourceLine)
+
+	listing, err := ListOwners(rules, []string{
+		"README.md",
+		"services/payments/v1/users.proto",
+		"services/payments/legacy/README.md",
+		"services/search/docs/design.md",
+	}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, Owners{
+		{Path: "README.md", Owners: mustOwners(t, "@org/docs", "@org/core")},
+		{Path: "services/payments/v1/users.proto", Owners: mustOwners(t, "@org/api", "@org/payments", "alice@example.com", "@org/core")},
+		{Path: "services/payments/legacy/README.md", Owners: mustOwners(t, "@org/legacy")},
+		{Path: "services/search/docs/design.md", Owners: mustOwners(t, "@org/core")},
+	}, listing)
+}
+
+func TestLoadNestedFSOptions(t *testing.T) {
+	fsys := nestedFS()
+	fsys["services/search/TEAM"] = &fstest.MapFile{Data: []byte("@org/search\n")}
+
+	// Only the listed files are considered
+	rules, err := LoadNestedFS(fsys, NestedOptions{
+		Names: []string{"TEAM"},
+		Files: []string{"services/search/TEAM"},
+	})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "/services/search/ @org/search", rules[0].String())
+
+	_, err = LoadNestedFS(fsys, NestedOptions{Names: []string{"OWNERS", "TEAM"}})
+	assert.EqualError(t, err, "services/search/TEAM: services/search has more than one OWNERS file")
+
+	fsys["docs/OWNERS"] = &fstest.MapFile{Data: []byte("@org/docs\ndocs/*.md not-an-owner\n")}
+	_, err = LoadNestedFS(fsys, NestedOptions{})
+	assert.EqualError(t, err, "docs/OWNERS: line 2: invalid owner format 'not-an-owner' at position 11")
+}
+
+func TestTraceOwnership(t *testing.T) {
+	rules, err := LoadNestedFS(nestedFS(), NestedOptions{})
+	require.NoError(t, err)
+
+	trace, err := TraceOwnership(rules, "services/payments/api.proto")
+	require.NoError(t, err)
+
+	summary := make([]string, 0, len(trace))
+	for _, match := range trace {
+		summary = append(summary, match.File+":"+*match.Rule)
+	}
+	assert.Equal(t, []string{
+		"OWNERS:*",
+		"services/payments/OWNERS:/services/payments/",
+		"services/payments/OWNERS:/services/payments/**/*.proto",
+	}, summary)
+
+	last, err := ExplainOwnership(rules, "services/payments/api.proto")
+	require.NoError(t, err)
+	assert.Equal(t, last, trace[len(trace)-1])
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 var (
@@ -14,19 +16,125 @@ var (
 	emailRegexp    = regexp.MustCompile(`\A[A-Z0-9a-z\._%\+\-]+@[A-Za-z0-9\.\-]+\.[A-Za-z]{2,6}\z`)
 	teamRegexp     = regexp.MustCompile(`\A@([a-zA-Z0-9\-]+\/[a-zA-Z0-9_\-]+)\z`)
 	usernameRegexp = regexp.MustCompile(`\A@([a-zA-Z0-9\-]+)\z`)
+	groupRegexp    = regexp.MustCompile(`\A@@([a-zA-Z0-9_\-\.]+)\z`)
+	// GitLab usernames and groups, which can be nested
+	gitlabOwnerRegexp = regexp.MustCompile(`\A@([a-zA-Z0-9_\-\.]+(?:/[a-zA-Z0-9_\-\.]+)*)\z`)
+	sectionRegexp     = regexp.MustCompile(`\A\s*\^?\[([^\]]+)\](?:\[[0-9]+\])?(.*)\z`)
+	selectionRegexp   = regexp.MustCompile(`\A(random|least_busy)\(([1-9][0-9]*)\)\z`)
 )
 
+// Dialect is a variant of the CODEOWNERS format.
+type Dialect string
+
+const (
+	// DialectGitHub is the format of GitHub CODEOWNERS files, and the default.
+	DialectGitHub Dialect = "github"
+	// DialectBitbucket is the format of Bitbucket code owners files, which adds groups defined
+	// with "@@@Name members..." and referred to as @@Name, reviewer selection strategies such as
+	// random(2) before the owners they apply to, and exclusions written !pattern, which leave
+	// matching files unowned.
+	DialectBitbucket Dialect = "bitbucket"
+	// DialectGitLab is the format of GitLab CODEOWNERS files, which adds sections, written [Name]
+	// and optionally followed by default owners, nested groups, and exclusions written !pattern,
+	// which leave matching files unowned by their section.
+	DialectGitLab Dialect = "gitlab"
+)
+
+// Dialects lists the supported dialects.
+var Dialects = []Dialect{DialectGitHub, DialectBitbucket, DialectGitLab}
+
+// ParseDialect returns the dialect with the given name.
+func ParseDialect(name string) (Dialect, error) {
+	for _, d := range Dialects {
+		if string(d) == name {
+			return d, nil
+		}
+	}
+	names := make([]string, len(Dialects))
+	for i, d := range Dialects {
+		names[i] = string(d)
+	}
+	return "", fmt.Errorf("unknown dialect %q: must be %s or %s", name, strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
+
+// supportsNegation reports whether the dialect has exclusions, written !pattern.
+func (d Dialect) supportsNegation() bool {
+	return d == DialectBitbucket || d == DialectGitLab
+}
+
+// ParseOption configures how a CODEOWNERS file is parsed.
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	dialect   Dialect
+	groups    map[string][]Owner
+	gitignore bool
+}
+
+// WithDialect parses files in the given dialect instead of GitHub's.
+func WithDialect(d Dialect) ParseOption {
+	return func(o *parseOptions) {
+		o.dialect = d
+	}
+}
+
+// WithGroups adds the groups defined in a Bitbucket file, and their members, to groups.
+func WithGroups(groups map[string][]Owner) ParseOption {
+	return func(o *parseOptions) {
+		o.groups = groups
+	}
+}
+
+// WithGitignorePatterns parses patterns with the full gitignore syntax, which GitHub doesn't
+// support: character classes such as [a-z] and [!0-9], non-ASCII names, and escaped special
+// characters such as \# and \[. Without it, brackets are rejected.
+func WithGitignorePatterns() ParseOption {
+	return func(o *parseOptions) {
+		o.gitignore = true
+	}
+}
+
+// newPattern compiles a pattern with the syntax given by the options.
+func (o parseOptions) newPattern(s string) (pattern, error) {
+	if o.gitignore {
+		return newGitignorePattern(s)
+	}
+	return newPattern(s)
+}
+
+func newParseOptions(opts []ParseOption) parseOptions {
+	o := parseOptions{dialect: DialectGitHub}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.groups == nil {
+		o.groups = make(map[string][]Owner)
+	}
+	return o
+}
+
 const (
 	statePattern = iota + 1
 	stateOwners
 )
 
 // ParseFile parses a CODEOWNERS file, returning a set of rules.
-func ParseFile(f io.Reader) ([]Rule, error) {
+func ParseFile(f io.Reader, opts ...ParseOption) ([]Rule, error) {
+	rules, _, err := parse(f, newParseOptions(opts))
+	return rules, err
+}
+
+// parse parses a CODEOWNERS file, returning its rules along with any comments and blank lines
+// after the last rule. Bitbucket group definitions and GitLab section headers are kept with the
+// comments.
+func parse(f io.Reader, o parseOptions) ([]Rule, string, error) {
 	rules := make([]Rule, 0)
 	scanner := bufio.NewScanner(f)
 
 	r := newRule()
+	// The GitLab section of the rules, and its default owners
+	var section string
+	var defaults []Owner
 
 	for lineNo := 1; scanner.Scan(); lineNo++ {
 		line := scanner.Text()
@@ -36,26 +144,126 @@ func ParseFile(f io.Reader) ([]Rule, error) {
 			continue
 		}
 
-		if err := parseRule(line, r); err != nil {
-			return rules, fmt.Errorf("line %d: %v", lineNo, err)
+		if o.dialect == DialectBitbucket && strings.HasPrefix(strings.TrimSpace(line), "@@@") {
+			if err := parseGroup(line, o.groups); err != nil {
+				return rules, "", fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			r.leadingComment += line + "\n"
+			continue
+		}
+
+		if match := sectionRegexp.FindStringSubmatch(line); o.dialect == DialectGitLab && match != nil {
+			var err error
+			if section, defaults, err = parseSection(match); err != nil {
+				return rules, "", fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			r.leadingComment += line + "\n"
+			continue
+		}
+
+		if err := parseDialectRule(line, r, o); err != nil {
+			return rules, "", fmt.Errorf("line %d: %v", lineNo, err)
 		} else {
 			r.SourceLine = lineNo
+			r.Section = section
+			if len(r.Owners) == 0 && !r.Negated && len(defaults) > 0 {
+				r.Owners = append(r.Owners, defaults...)
+				r.defaultOwners = true
+			}
 			rules = append(rules, *r)
 			r = newRule()
 		}
 	}
 
-	return rules, nil
+	return rules, r.leadingComment, scanner.Err()
+}
+
+// parseGroup parses a Bitbucket group definition, "@@@Name members...", adding it to groups.
+func parseGroup(line string, groups map[string][]Owner) error {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+
+	match := groupRegexp.FindStringSubmatch(strings.TrimPrefix(fields[0], "@"))
+	if match == nil {
+		return fmt.Errorf("invalid group name '%s'", fields[0])
+	}
+	name := match[1]
+	if _, ok := groups[name]; ok {
+		return fmt.Errorf("group @@%s is defined more than once", name)
+	}
+
+	members := make([]Owner, 0, len(fields)-1)
+	for _, field := range fields[1:] {
+		member, err := newDialectOwner(field, DialectBitbucket)
+		if err != nil {
+			return err
+		}
+		members = append(members, member)
+	}
+	groups[name] = members
+	return nil
+}
+
+// parseSection parses a GitLab section header, [Name] or ^[Name][approvals], returning its name
+// and default owners.
+func parseSection(match []string) (string, []Owner, error) {
+	rest := match[2]
+	if i := strings.Index(rest, "#"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	var defaults []Owner
+	for _, field := range strings.Fields(rest) {
+		owner, err := newDialectOwner(field, DialectGitLab)
+		if err != nil {
+			return "", nil, err
+		}
+		defaults = append(defaults, owner)
+	}
+	return strings.TrimSpace(match[1]), defaults, nil
 }
 
 func parseRule(ruleStr string, r *Rule) error {
+	return parseDialectRule(ruleStr, r, parseOptions{dialect: DialectGitHub})
+}
+
+func parseDialectRule(ruleStr string, r *Rule, o parseOptions) error {
+	d := o.dialect
 	state := statePattern
 	escaped := false
+	// The selection strategy of the owners that follow, and whether any have yet
+	var selection Selection
+	selectionOwners := true
+
+	addOwner := func(s string) error {
+		if d == DialectBitbucket {
+			if sel, ok := parseSelection(s); ok {
+				selection, selectionOwners = sel, false
+				return nil
+			}
+		}
+		owner, err := newDialectOwner(s, d)
+		if err != nil {
+			return err
+		}
+		if selection != (Selection{}) {
+			if r.Selections == nil {
+				r.Selections = make(map[Owner]Selection)
+			}
+			r.Selections[owner] = selection
+		}
+		r.Owners = append(r.Owners, owner)
+		selectionOwners = true
+		return nil
+	}
 
 	buf := bytes.Buffer{}
 	for i, ch := range strings.TrimSpace(ruleStr) {
-		// Comments consume the rest of the line and stop further parsing
-		if ch == '#' {
+		// Comments consume the rest of the line and stop further parsing, unless the # is escaped
+		// in a gitignore pattern
+		if ch == '#' && !(o.gitignore && state == statePattern && escaped) {
 			r.trailingComment = strings.TrimSpace(ruleStr[i:])
 			break
 		}
@@ -63,6 +271,14 @@ func parseRule(ruleStr string, r *Rule) error {
 		switch state {
 		case statePattern:
 			switch {
+			case ch == '!' && i == 0:
+				// Exclusions leave matching files unowned
+				if !d.supportsNegation() {
+					return fmt.Errorf("negation is not supported by GitHub")
+				}
+				r.Negated = true
+				continue
+
 			case ch == '\\':
 				// Escape the next character (important for whitespace while parsing), but
 				// don't lose the backslash as it's part of the pattern
@@ -72,7 +288,7 @@ func parseRule(ruleStr string, r *Rule) error {
 
 			case isWhitespace(ch) && !escaped:
 				// Unescaped whitespace means this is the end of the pattern
-				pattern, err := newPattern(buf.String())
+				pattern, err := o.newPattern(buf.String())
 				if err != nil {
 					return err
 				}
@@ -80,7 +296,7 @@ func parseRule(ruleStr string, r *Rule) error {
 				buf.Reset()
 				state = stateOwners
 
-			case isPatternChar(ch) || (isWhitespace(ch) && escaped):
+			case isPatternChar(ch) || (o.gitignore && isGitignorePatternChar(ch)) || (isWhitespace(ch) && escaped):
 				// Keep any valid pattern characters and escaped whitespace
 				buf.WriteRune(ch)
 
@@ -97,15 +313,13 @@ func parseRule(ruleStr string, r *Rule) error {
 				// through whitespace before or after owner declarations
 				if buf.Len() > 0 {
 					ownerStr := buf.String()
-					owner, err := newOwner(ownerStr)
-					if err != nil {
+					if err := addOwner(ownerStr); err != nil {
 						return fmt.Errorf("%s at position %d", err.Error(), i+1-len(ownerStr))
 					}
-					r.Owners = append(r.Owners, owner.String())
 					buf.Reset()
 				}
 
-			case isOwnersChar(ch):
+			case isOwnersChar(ch) || (d == DialectBitbucket && (ch == '(' || ch == ')')):
 				// Write valid owner characters to the buffer
 				buf.WriteRune(ch)
 
@@ -123,7 +337,7 @@ func parseRule(ruleStr string, r *Rule) error {
 			return fmt.Errorf("unexpected end of rule")
 		}
 
-		pattern, err := newPattern(buf.String())
+		pattern, err := o.newPattern(buf.String())
 		if err != nil {
 			return err
 		}
@@ -133,17 +347,33 @@ func parseRule(ruleStr string, r *Rule) error {
 		// If there's an owner left in the buffer, don't leave it behind
 		if buf.Len() > 0 {
 			ownerStr := buf.String()
-			owner, err := newOwner(ownerStr)
-			if err != nil {
+			if err := addOwner(ownerStr); err != nil {
 				return fmt.Errorf("%s at position %d", err.Error(), len(ruleStr)+1-len(ownerStr))
 			}
-			r.Owners = append(r.Owners, owner.String())
 		}
 	}
 
+	switch {
+	case !selectionOwners:
+		return fmt.Errorf("selection strategy %s isn't followed by owners", selection)
+	case r.Negated && len(r.Owners) > 0:
+		return fmt.Errorf("exclusion !%s can't have owners", r.pattern.pattern)
+	}
 	return nil
 }
 
+// ParseOwner parses an owner as written in CODEOWNERS: an @username, an @org/team, or an email
+// address.
+func ParseOwner(s string) (Owner, error) {
+	return newOwner(s)
+}
+
+// ParseDialectOwner parses an owner as written in a file of the given dialect, which also allows
+// Bitbucket @@groups and GitLab nested groups such as @org/group/subgroup.
+func ParseDialectOwner(s string, d Dialect) (Owner, error) {
+	return newDialectOwner(s, d)
+}
+
 // newOwner figures out which kind of owner this is and returns an Owner struct
 func newOwner(s string) (Owner, error) {
 	match := emailRegexp.FindStringSubmatch(s)
@@ -164,6 +394,54 @@ func newOwner(s string) (Owner, error) {
 	return Owner{}, fmt.Errorf("invalid owner format '%s'", s)
 }
 
+// newDialectOwner parses an owner as written in a file of the given dialect, which for Bitbucket
+// includes @@group references, and for GitLab nested groups.
+func newDialectOwner(s string, d Dialect) (Owner, error) {
+	switch d {
+	case DialectBitbucket:
+		if match := groupRegexp.FindStringSubmatch(s); match != nil {
+			return Owner{Value: match[1], Type: GroupOwner}, nil
+		}
+	case DialectGitLab:
+		if owner, err := newOwner(s); err == nil {
+			return owner, nil
+		}
+		if match := gitlabOwnerRegexp.FindStringSubmatch(s); match != nil {
+			if strings.Contains(match[1], "/") {
+				return Owner{Value: match[1], Type: TeamOwner}, nil
+			}
+			return Owner{Value: match[1], Type: UsernameOwner}, nil
+		}
+	}
+	return newOwner(s)
+}
+
+// newAnyOwner parses an owner as written in a file of any dialect.
+func newAnyOwner(s string) (Owner, error) {
+	for _, d := range Dialects {
+		if owner, err := newDialectOwner(s, d); err == nil {
+			return owner, nil
+		}
+	}
+	return newOwner(s)
+}
+
+// parseSelection parses a Bitbucket reviewer selection strategy: all, random(n) or least_busy(n).
+func parseSelection(s string) (Selection, bool) {
+	if s == StrategyAll {
+		return Selection{Strategy: StrategyAll}, true
+	}
+	match := selectionRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return Selection{}, false
+	}
+	count, err := strconv.Atoi(match[2])
+	if err != nil {
+		return Selection{}, false
+	}
+	return Selection{Strategy: match[1], Count: count}, true
+}
+
 func isWhitespace(ch rune) bool {
 	return ch == ' ' || ch == '\t' || ch == '\n'
 }
@@ -181,6 +459,12 @@ func isPatternChar(ch rune) bool {
 	return isAlphanumeric(ch)
 }
 
+// isGitignorePatternChar matches the characters that are also allowed in gitignore patterns, which
+// include brackets and any printable character
+func isGitignorePatternChar(ch rune) bool {
+	return unicode.IsGraphic(ch) && !isWhitespace(ch)
+}
+
 // isOwnersChar matches characters that are allowed in owner definitions
 func isOwnersChar(ch rune) bool {
 	switch ch {
@@ -1,10 +1,12 @@
 package codeowners
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFormatting(t *testing.T) {
@@ -77,7 +79,7 @@ func TestParseRule(t *testing.T) {
 				{
 					SourceLine:     5,
 					pattern:        mustBuildPattern(t, "file.txt"),
-					Owners:         []string{"@user"},
+					Owners:         []Owner{{Value: "user", Type: UsernameOwner}},
 					leadingComment: "# comment a\n\n# comment b\n\n",
 				},
 			},
@@ -89,7 +91,7 @@ func TestParseRule(t *testing.T) {
 				{
 					SourceLine: 1,
 					pattern:    mustBuildPattern(t, "file.txt"),
-					Owners:     []string{"@user"},
+					Owners:     []Owner{{Value: "user", Type: UsernameOwner}},
 				},
 			},
 		},
@@ -100,7 +102,7 @@ func TestParseRule(t *testing.T) {
 				{
 					SourceLine: 1,
 					pattern:    mustBuildPattern(t, "file.txt"),
-					Owners:     []string{"@org/team"},
+					Owners:     []Owner{{Value: "org/team", Type: TeamOwner}},
 				},
 			},
 		},
@@ -111,7 +113,7 @@ func TestParseRule(t *testing.T) {
 				{
 					SourceLine: 1,
 					pattern:    mustBuildPattern(t, "file.txt"),
-					Owners:     []string{"foo@example.com"},
+					Owners:     []Owner{{Value: "foo@example.com", Type: EmailOwner}},
 				},
 			},
 		},
@@ -121,10 +123,10 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine: 1,
 				pattern:    mustBuildPattern(t, "file.txt"),
-				Owners: []string{
-					"@user",
-					"@org/team",
-					"foo@example.com",
+				Owners: []Owner{
+					{Value: "user", Type: UsernameOwner},
+					{Value: "org/team", Type: TeamOwner},
+					{Value: "foo@example.com", Type: EmailOwner},
 				},
 			},
 			},
@@ -135,7 +137,7 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine: 1,
 				pattern:    mustBuildPattern(t, "d?r/*"),
-				Owners:     []string{"@user"},
+				Owners:     []Owner{{Value: "user", Type: UsernameOwner}},
 			},
 			},
 		},
@@ -145,7 +147,7 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine: 1,
 				pattern:    mustBuildPattern(t, "foo\\ bar"),
-				Owners:     []string{"@user"},
+				Owners:     []Owner{{Value: "user", Type: UsernameOwner}},
 			}},
 		},
 		{
@@ -154,7 +156,7 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine:      1,
 				pattern:         mustBuildPattern(t, "file.txt"),
-				Owners:          []string{"@user"},
+				Owners:          []Owner{{Value: "user", Type: UsernameOwner}},
 				trailingComment: "# some comment",
 			}},
 		},
@@ -164,7 +166,7 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine:      1,
 				pattern:         mustBuildPattern(t, "pattern"),
-				Owners:          []string{},
+				Owners:          []Owner{},
 				trailingComment: "",
 			}},
 		},
@@ -174,7 +176,7 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine:      1,
 				pattern:         mustBuildPattern(t, "pattern"),
-				Owners:          []string{},
+				Owners:          []Owner{},
 				trailingComment: "# but no more",
 			}},
 		},
@@ -184,7 +186,7 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine:      1,
 				pattern:         mustBuildPattern(t, "pattern"),
-				Owners:          []string{},
+				Owners:          []Owner{},
 				trailingComment: "",
 			}},
 		},
@@ -194,7 +196,7 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine:      1,
 				pattern:         mustBuildPattern(t, "pattern"),
-				Owners:          []string{"@user"},
+				Owners:          []Owner{{Value: "user", Type: UsernameOwner}},
 				trailingComment: "",
 			}},
 		},
@@ -204,7 +206,7 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine:      1,
 				pattern:         mustBuildPattern(t, "pattern"),
-				Owners:          []string{},
+				Owners:          []Owner{},
 				trailingComment: "",
 			}},
 		},
@@ -214,7 +216,7 @@ func TestParseRule(t *testing.T) {
 			expected: []Rule{{
 				SourceLine: 1,
 				pattern:    mustBuildPattern(t, "src/app/(nonauth)/forgot/**/*"),
-				Owners:     []string{"@user"},
+				Owners:     []Owner{{Value: "user", Type: UsernameOwner}},
 			}},
 		},
 
@@ -246,6 +248,68 @@ func TestParseRule(t *testing.T) {
 	}
 }
 
+func TestParseGitignorePatterns(t *testing.T) {
+	file := "docs/[draft].md @docs\n/ソース/*.[ch] @src # sources\n\\#notes.md @notes\n"
+
+	// GitHub patterns can't have brackets or non-ASCII names
+	_, err := ParseFile(strings.NewReader(file))
+	assert.EqualError(t, err, "line 1: unexpected character '[' at position 6")
+
+	rules, err := ParseFile(strings.NewReader(file), WithGitignorePatterns())
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	assert.Equal(t, "/ソース/*.[ch]", rules[1].RawPattern())
+	assert.Equal(t, "# sources", rules[1].trailingComment)
+	assert.Equal(t, `\#notes.md`, rules[2].RawPattern())
+
+	for path, expected := range map[string]bool{
+		"docs/d.md":       true,
+		"docs/[draft].md": false,
+		"ソース/main.c":      true,
+		"ソース/main.go":     false,
+		"#notes.md":       true,
+	} {
+		var matched bool
+		for _, rule := range rules {
+			ok, err := rule.Match(path)
+			require.NoError(t, err)
+			matched = matched || ok
+		}
+		assert.Equal(t, expected, matched, path)
+	}
+
+	assert.Equal(t, "character class [draft]", rules[0].UnsupportedSyntax())
+	assert.Equal(t, "character class [ch]", rules[1].UnsupportedSyntax())
+	assert.Equal(t, "escaped leading #", rules[2].UnsupportedSyntax())
+
+	_, err = ParseFile(strings.NewReader("/log[9-0].txt @logs\n"), WithGitignorePatterns())
+	assert.EqualError(t, err, "line 1: invalid character range 9-0")
+	_, err = ParseFile(strings.NewReader("/log[[:num:]].txt @logs\n"), WithGitignorePatterns())
+	assert.EqualError(t, err, "line 1: unknown character class [:num:]")
+}
+
+func TestOwnerJSON(t *testing.T) {
+	owners := append(mustOwners(t, "@user", "@org/team", "foo@example.com"), Unowned)
+
+	data, err := json.Marshal(owners)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"name": "@user", "type": "username"},
+		{"name": "@org/team", "type": "team", "org": "org"},
+		{"name": "foo@example.com", "type": "email"},
+		{"name": "(unowned)", "type": "unowned"}
+	]`, string(data))
+
+	var decoded []Owner
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, owners, decoded)
+
+	require.NoError(t, json.Unmarshal([]byte(`["@user", "@org/team", "foo@example.com", "(unowned)"]`), &decoded))
+	assert.Equal(t, owners, decoded)
+
+	assert.EqualError(t, json.Unmarshal([]byte(`["user"]`), &decoded), "invalid owner format 'user'")
+}
+
 func mustBuildPattern(t *testing.T, pat string) pattern {
 	p, err := newPattern(pat)
 	if err != nil {
@@ -253,3 +317,15 @@ func mustBuildPattern(t *testing.T, pat string) pattern {
 	}
 	return p
 }
+
+func mustOwners(t *testing.T, owners ...string) []Owner {
+	out := make([]Owner, 0, len(owners))
+	for _, s := range owners {
+		owner, err := newOwner(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, owner)
+	}
+	return out
+}
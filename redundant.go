@@ -0,0 +1,120 @@
+package codeowners
+
+// RedundancyReason explains why removing a rule changes no file's ownership.
+type RedundancyReason string
+
+const (
+	// Unmatched rules match none of the files.
+	Unmatched RedundancyReason = "unmatched"
+	// Shadowed rules only match files that later rules own.
+	Shadowed RedundancyReason = "shadowed"
+	// SameOwners rules own files that the earlier rules give the same owners, and may match files
+	// that later rules own.
+	SameOwners RedundancyReason = "same-owners"
+)
+
+// RedundantRule is a rule that can be removed without changing the ownership of any file.
+type RedundantRule struct {
+	Rule    Rule             `json:"-"`
+	Line    int              `json:"line"`
+	Pattern string           `json:"pattern"`
+	Owners  []Owner          `json:"owners"`
+	Reason  RedundancyReason `json:"reason"`
+	// By lists the source lines of the rules that own the rule's files without it, if any.
+	By []int `json:"by"`
+}
+
+// RedundantRules returns the rules that can be removed from the ruleset without changing the
+// ownership of any of the files, in the order they appear. Unlike ConsolidateTree, which compares
+// rules to each other, it only looks at files, so any rule is considered whatever its pattern.
+//
+// Removing one redundant rule can make another one necessary, as with a rule that is written
+// twice. Rules are considered in order and each is checked as if the redundant rules before it were
+// already removed, so all the rules returned can be removed together.
+//
+// In GitLab files, rules are only compared to rules in the same section, and exclusions are only
+// redundant when they match none of the files.
+func RedundantRules(rules Ruleset, files []string) ([]RedundantRule, error) {
+	// The rules matching each file, in order, and the files matching each rule
+	matches := make([][]int, len(files))
+	matched := make([][]int, len(rules))
+	for i := range rules {
+		for f, file := range files {
+			ok, err := rules[i].Match(file)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matches[f] = append(matches[f], i)
+				matched[i] = append(matched[i], f)
+			}
+		}
+	}
+
+	removed := make([]bool, len(rules))
+	// owner returns the last remaining rule of the section matching the file before the given
+	// index, or -1
+	owner := func(f, before int, section string) int {
+		for j := len(matches[f]) - 1; j >= 0; j-- {
+			if i := matches[f][j]; i < before && !removed[i] && !rules[i].Negated && rules[i].Section == section {
+				return i
+			}
+		}
+		return -1
+	}
+
+	redundant := make([]RedundantRule, 0)
+	for i := range rules {
+		reason := Unmatched
+		if len(matched[i]) > 0 {
+			reason = Shadowed
+		}
+		if rules[i].Negated && len(matched[i]) > 0 {
+			continue
+		}
+
+		by := make(map[int]bool)
+		for _, f := range matched[i] {
+			if later := owner(f, len(rules), rules[i].Section); later != i {
+				by[later] = true
+				continue
+			}
+
+			reason = SameOwners
+			earlier := owner(f, i, rules[i].Section)
+			var owners []Owner
+			if earlier >= 0 {
+				owners = rules[earlier].Owners
+			}
+			if !sameOwners(owners, rules[i].Owners) {
+				reason = ""
+				break
+			}
+			if earlier >= 0 {
+				by[earlier] = true
+			}
+		}
+		if reason == "" {
+			continue
+		}
+
+		lines := make([]int, 0, len(by))
+		for j := range rules {
+			if by[j] {
+				lines = append(lines, rules[j].SourceLine)
+			}
+		}
+
+		removed[i] = true
+		redundant = append(redundant, RedundantRule{
+			Rule:    rules[i],
+			Line:    rules[i].SourceLine,
+			Pattern: rules[i].RawPattern(),
+			Owners:  rules[i].Owners,
+			Reason:  reason,
+			By:      lines,
+		})
+	}
+
+	return redundant, nil
+}
@@ -0,0 +1,116 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedundantRules(t *testing.T) {
+	rules := mustParse(t, `* @org/core
+/docs/ @org/docs
+/docs/api/ @org/docs
+/legacy/ @org/legacy
+/src/app.go @org/app
+/src/ @org/src
+/build/ @org/build
+/build/
+*.md @org/docs
+/docs/*.md @org/docs
+/vendor/
+`)
+	files := []string{
+		"README.md",
+		"go.mod",
+		"docs/Makefile",
+		"docs/index.md",
+		"docs/api/users.md",
+		"docs/api/schema.json",
+		"src/app.go",
+		"src/lib.go",
+		"build/Makefile",
+	}
+
+	redundant, err := RedundantRules(rules, files)
+	require.NoError(t, err)
+
+	summary := make([]string, 0, len(redundant))
+	for _, r := range redundant {
+		summary = append(summary, r.Pattern+" "+string(r.Reason))
+	}
+	assert.Equal(t, []string{
+		"/docs/api/ same-owners",
+		"/legacy/ unmatched",
+		"/src/app.go shadowed",
+		"/build/ shadowed",
+		"/docs/*.md same-owners",
+		"/vendor/ unmatched",
+	}, summary)
+
+	assert.Equal(t, RedundantRule{
+		Rule:    rules[2],
+		Line:    3,
+		Pattern: "/docs/api/",
+		Owners:  mustOwners(t, "@org/docs"),
+		Reason:  SameOwners,
+		By:      []int{2, 9},
+	}, redundant[0])
+	assert.Equal(t, []int{6}, redundant[2].By)
+	assert.Equal(t, []int{}, redundant[1].By)
+
+	// Removing every redundant rule together changes no file's ownership
+	trimmed := make(Ruleset, 0, len(rules))
+	for _, rule := range rules {
+		keep := true
+		for _, r := range redundant {
+			keep = keep && r.Line != rule.SourceLine
+		}
+		if keep {
+			trimmed = append(trimmed, rule)
+		}
+	}
+	before, err := ListOwners(rules, files, nil, false)
+	require.NoError(t, err)
+	after, err := ListOwners(trimmed, files, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestRedundantRulesDuplicates(t *testing.T) {
+	rules := mustParse(t, `/src/ @org/a
+/src/ @org/a
+`)
+
+	redundant, err := RedundantRules(rules, []string{"src/main.go"})
+	require.NoError(t, err)
+	require.Len(t, redundant, 1)
+	assert.Equal(t, 1, redundant[0].Line)
+	assert.Equal(t, Shadowed, redundant[0].Reason)
+}
+
+func TestRedundantRulesSections(t *testing.T) {
+	rules, err := ParseFile(strings.NewReader(gitlabFile), WithDialect(DialectGitLab))
+	require.NoError(t, err)
+
+	// * isn't shadowed by the rules of later sections, and exclusions that match are kept
+	redundant, err := RedundantRules(rules, []string{"README.md", "CHANGELOG.md", "api/server.go", "api/generated/types.go"})
+	require.NoError(t, err)
+	assert.Empty(t, redundant)
+
+	// Rules are still shadowed by later rules in their own section
+	rules, err = ParseFile(strings.NewReader("[Docs] @writers\n/docs/\n/docs/ @org/docs\n"), WithDialect(DialectGitLab))
+	require.NoError(t, err)
+	redundant, err = RedundantRules(rules, []string{"docs/index.md"})
+	require.NoError(t, err)
+	require.Len(t, redundant, 1)
+	assert.Equal(t, RedundantRule{
+		Rule:    rules[0],
+		Line:    2,
+		Pattern: "/docs/",
+		Owners:  mustOwners(t, "@writers"),
+		Reason:  Shadowed,
+		By:      []int{3},
+	}, redundant[0])
+}
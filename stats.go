@@ -5,7 +5,7 @@ import (
 )
 
 type FilesPerOwner struct {
-	Owner      string  `json:"owner"`
+	Owner      Owner   `json:"owner"`
 	Count      int     `json:"fileCount"`
 	Percentage float64 `json:"percentage"`
 }
@@ -21,7 +21,7 @@ type OwnerStats struct {
 func CalculateOwnershipStats(files Owners) OwnerStats {
 	fileCount := len(files)
 
-	stats := make(map[string]int)
+	stats := make(map[Owner]int)
 	for _, file := range files {
 		for _, owner := range file.Owners {
 			stats[owner]++
@@ -37,15 +37,15 @@ func CalculateOwnershipStats(files Owners) OwnerStats {
 	sort.Slice(filesPerOwner, func(i, j int) bool {
 		countsEqual := filesPerOwner[i].Count == filesPerOwner[j].Count
 		if countsEqual {
-			return filesPerOwner[i].Owner > filesPerOwner[j].Owner
+			return filesPerOwner[i].Owner.String() > filesPerOwner[j].Owner.String()
 		}
 		return filesPerOwner[i].Count > filesPerOwner[j].Count
 	})
 
-	unownedCount := stats["(unowned)"]
+	unownedCount := stats[Unowned]
 	ownedCount := fileCount - unownedCount
 	totalOwners := len(stats)
-	if _, hasUnowned := stats["(unowned)"]; hasUnowned {
+	if _, hasUnowned := stats[Unowned]; hasUnowned {
 		totalOwners--
 	}
 
@@ -28,7 +28,7 @@ func TestCalculateOwnershipStats(t *testing.T) {
 			files: Owners{
 				{
 					Path:   "file1.txt",
-					Owners: []string{"@teamA"},
+					Owners: mustOwners(t, "@teamA"),
 				},
 			},
 			expected: OwnerStats{
@@ -38,7 +38,7 @@ func TestCalculateOwnershipStats(t *testing.T) {
 				OwnerCount:   1,
 				FilesPerOwner: []FilesPerOwner{
 					{
-						Owner:      "@teamA",
+						Owner:      mustOwners(t, "@teamA")[0],
 						Count:      1,
 						Percentage: 100.0,
 					},
@@ -50,7 +50,7 @@ func TestCalculateOwnershipStats(t *testing.T) {
 			files: Owners{
 				{
 					Path:   "file1.txt",
-					Owners: []string{"(unowned)"},
+					Owners: []Owner{Unowned},
 				},
 			},
 			expected: OwnerStats{
@@ -60,7 +60,7 @@ func TestCalculateOwnershipStats(t *testing.T) {
 				OwnerCount:   0,
 				FilesPerOwner: []FilesPerOwner{
 					{
-						Owner:      "(unowned)",
+						Owner:      Unowned,
 						Count:      1,
 						Percentage: 100.0,
 					},
@@ -72,15 +72,15 @@ func TestCalculateOwnershipStats(t *testing.T) {
 			files: Owners{
 				{
 					Path:   "file1.txt",
-					Owners: []string{"@teamA", "@teamB"},
+					Owners: mustOwners(t, "@teamA", "@teamB"),
 				},
 				{
 					Path:   "file2.txt",
-					Owners: []string{"@teamA"},
+					Owners: mustOwners(t, "@teamA"),
 				},
 				{
 					Path:   "file3.txt",
-					Owners: []string{"(unowned)"},
+					Owners: []Owner{Unowned},
 				},
 			},
 			expected: OwnerStats{
@@ -90,17 +90,17 @@ func TestCalculateOwnershipStats(t *testing.T) {
 				OwnerCount:   2,
 				FilesPerOwner: []FilesPerOwner{
 					{
-						Owner:      "@teamA",
+						Owner:      mustOwners(t, "@teamA")[0],
 						Count:      2,
 						Percentage: 66.67,
 					},
 					{
-						Owner:      "@teamB",
+						Owner:      mustOwners(t, "@teamB")[0],
 						Count:      1,
 						Percentage: 33.33,
 					},
 					{
-						Owner:      "(unowned)",
+						Owner:      Unowned,
 						Count:      1,
 						Percentage: 33.33,
 					},
@@ -0,0 +1,268 @@
+package codeowners
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Commit is the author of a commit and the files it changed, as used by SuggestOwners.
+type Commit struct {
+	Author string
+	Files  []string
+}
+
+// Roster maps commit author emails to the owners, usually teams, they belong to.
+type Roster map[string]Owner
+
+// ParseRoster parses a roster. Each line holds an owner followed by the emails of its members,
+// separated by whitespace. Blank lines and lines starting with # are ignored:
+//
+//	@org/payments alice@example.com bob@example.com
+//	@org/search carol@example.com
+func ParseRoster(f io.Reader) (Roster, error) {
+	roster := make(Roster)
+	scanner := bufio.NewScanner(f)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		owner, err := newOwner(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		for _, email := range fields[1:] {
+			if !emailRegexp.MatchString(email) {
+				return nil, fmt.Errorf("line %d: invalid email '%s'", lineNo, email)
+			}
+			email = strings.ToLower(email)
+			if other, ok := roster[email]; ok && other != owner {
+				return nil, fmt.Errorf("line %d: %s is already a member of %s", lineNo, email, other)
+			}
+			roster[email] = owner
+		}
+	}
+
+	return roster, scanner.Err()
+}
+
+// SuggestOptions controls how SuggestOwners attributes and groups files.
+type SuggestOptions struct {
+	// Roster maps authors to owners. Authors missing from it are ignored. Without a roster, each
+	// author is suggested as an owner by email.
+	Roster Roster
+	// MinConfidence is the share of commits, between 0 and 1, that an owner needs to be suggested
+	// for a file or directory.
+	MinConfidence float64
+}
+
+// Suggestion is a rule proposed by SuggestOwners for files that are currently unowned.
+type Suggestion struct {
+	Rule Rule `json:"-"`
+	// Pattern and Owner are the rule's pattern and single owner.
+	Pattern string `json:"pattern"`
+	Owner   Owner  `json:"owner"`
+	// Files is the number of unowned files the rule covers.
+	Files int `json:"fileCount"`
+	// Commits is the number of commits to those files by known authors, and Confidence is the share
+	// of them made by the owner.
+	Commits    int     `json:"commitCount"`
+	Confidence float64 `json:"confidence"`
+}
+
+// suggestNode is a file or directory in the tree of files considered by SuggestOwners.
+type suggestNode struct {
+	path     string
+	isDir    bool
+	children map[string]*suggestNode
+	// unowned files beneath the node, and whether there are owned files beneath it too
+	unowned int
+	owned   bool
+	commits map[Owner]int
+	total   int
+}
+
+// SuggestOwners proposes rules for the unowned files in a ListOwners listing, based on who
+// changed them in the given history. A directory gets a single rule when every file beneath it is
+// unowned and one owner made enough of its commits; otherwise its subdirectories and files are
+// considered separately. Rules are appended after the existing ones, so patterns never cover owned
+// files, whose ownership is left unchanged.
+//
+// Suggestions are returned in path order, along with the unowned files no owner could be suggested
+// for.
+func SuggestOwners(files Owners, history []Commit, opts SuggestOptions) ([]Suggestion, []string) {
+	root := &suggestNode{isDir: true, children: make(map[string]*suggestNode), commits: make(map[Owner]int)}
+	nodes := map[string]*suggestNode{"": root}
+
+	var add func(p string, isDir bool) *suggestNode
+	add = func(p string, isDir bool) *suggestNode {
+		if node, ok := nodes[p]; ok {
+			return node
+		}
+		parent := add(parentDir(p), true)
+		node := &suggestNode{path: p, isDir: isDir, commits: make(map[Owner]int)}
+		if isDir {
+			node.children = make(map[string]*suggestNode)
+		}
+		parent.children[p] = node
+		nodes[p] = node
+		return node
+	}
+
+	for _, file := range files {
+		unowned := len(file.Owners) == 1 && file.Owners[0] == Unowned
+		for node := add(file.Path, false); ; node = nodes[parentDir(node.path)] {
+			if unowned {
+				node.unowned++
+			} else {
+				node.owned = true
+			}
+			if node == root {
+				break
+			}
+		}
+	}
+
+	for _, commit := range history {
+		owner, ok := opts.authorOwner(commit.Author)
+		if !ok {
+			continue
+		}
+
+		// Count each commit once per directory, however many of its files it changed
+		touched := make(map[*suggestNode]bool)
+		for _, file := range commit.Files {
+			node, ok := nodes[file]
+			if !ok || node.isDir || node.owned {
+				continue
+			}
+			for ; !touched[node]; node = nodes[parentDir(node.path)] {
+				touched[node] = true
+				if node == root {
+					break
+				}
+			}
+		}
+		for node := range touched {
+			node.commits[owner]++
+			node.total++
+		}
+	}
+
+	suggestions := make([]Suggestion, 0)
+	unsuggested := make([]string, 0)
+
+	var visit func(node *suggestNode)
+	visit = func(node *suggestNode) {
+		if node.unowned == 0 {
+			return
+		}
+
+		// A catch-all rule at the end of the file would override every other rule for new files
+		if !node.owned && node != root {
+			if suggestion, ok := node.suggest(opts.MinConfidence); ok {
+				suggestions = append(suggestions, suggestion)
+				return
+			}
+		}
+
+		if !node.isDir {
+			unsuggested = append(unsuggested, node.path)
+			return
+		}
+
+		children := make([]string, 0, len(node.children))
+		for p := range node.children {
+			children = append(children, p)
+		}
+		sort.Strings(children)
+		for _, p := range children {
+			visit(node.children[p])
+		}
+	}
+	visit(root)
+
+	return suggestions, unsuggested
+}
+
+// authorOwner returns the owner to credit for an author's commits.
+func (opts SuggestOptions) authorOwner(email string) (Owner, bool) {
+	if opts.Roster != nil {
+		owner, ok := opts.Roster[strings.ToLower(email)]
+		return owner, ok
+	}
+
+	owner, err := newOwner(email)
+	return owner, err == nil && owner.Type == EmailOwner
+}
+
+// suggest returns a rule for every file beneath the node, if one owner made enough of its commits.
+func (n *suggestNode) suggest(minConfidence float64) (Suggestion, bool) {
+	if n.total == 0 {
+		return Suggestion{}, false
+	}
+
+	var best Owner
+	for owner, count := range n.commits {
+		if count > n.commits[best] || (count == n.commits[best] && owner.String() < best.String()) {
+			best = owner
+		}
+	}
+
+	confidence := float64(n.commits[best]) / float64(n.total)
+	if confidence < minConfidence {
+		return Suggestion{}, false
+	}
+
+	rule, err := NewRule(suggestPattern(n.path, n.isDir), best)
+	if err != nil {
+		// Not every path can be written as a CODEOWNERS pattern
+		return Suggestion{}, false
+	}
+
+	return Suggestion{
+		Rule:       rule,
+		Pattern:    rule.RawPattern(),
+		Owner:      best,
+		Files:      n.unowned,
+		Commits:    n.total,
+		Confidence: confidence,
+	}, true
+}
+
+// suggestPattern returns a pattern matching exactly the file, or everything in the directory.
+func suggestPattern(p string, isDir bool) string {
+	pattern := "/" + escapePattern(p)
+	if isDir {
+		pattern += "/"
+	}
+	return pattern
+}
+
+// escapePattern escapes the characters of a path that are special in patterns.
+func escapePattern(p string) string {
+	var b strings.Builder
+	for _, ch := range p {
+		switch ch {
+		case ' ', '\t', '*', '?', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+func parentDir(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
@@ -0,0 +1,94 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestOwners(t *testing.T) {
+	rules := mustParse(t, `/owned/ @org/owned
+`)
+	files := []string{
+		"owned/a.go",
+		"payments/api.go",
+		"payments/db/store.go",
+		"search/index.go",
+		"search/query.go",
+		"mixed/owned.go",
+		"mixed/unowned.go",
+		"docs/with space.md",
+		"untouched/file.txt",
+	}
+	rules = append(rules, mustParse(t, "/mixed/owned.go @org/mixed\n")...)
+
+	listing, err := ListOwners(rules, files, nil, false)
+	require.NoError(t, err)
+
+	roster, err := ParseRoster(strings.NewReader(`# Teams
+@org/payments alice@example.com Bob@example.com
+@org/search carol@example.com
+`))
+	require.NoError(t, err)
+
+	history := []Commit{
+		{Author: "alice@example.com", Files: []string{"payments/api.go", "payments/db/store.go"}},
+		{Author: "bob@example.com", Files: []string{"payments/db/store.go", "owned/a.go"}},
+		{Author: "carol@example.com", Files: []string{"payments/api.go", "search/index.go"}},
+		{Author: "carol@example.com", Files: []string{"search/query.go", "mixed/unowned.go"}},
+		{Author: "alice@example.com", Files: []string{"search/query.go"}},
+		{Author: "carol@example.com", Files: []string{"docs/with space.md"}},
+		{Author: "stranger@example.com", Files: []string{"untouched/file.txt"}},
+	}
+
+	suggestions, unsuggested := SuggestOwners(listing, history, SuggestOptions{Roster: roster, MinConfidence: 0.6})
+
+	patterns := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		patterns = append(patterns, s.Rule.String())
+	}
+	assert.Equal(t, []string{
+		`/docs/ @org/search`,
+		`/mixed/unowned.go @org/search`,
+		`/payments/ @org/payments`,
+		`/search/ @org/search`,
+	}, patterns)
+
+	assert.Equal(t, Suggestion{
+		Rule:       suggestions[2].Rule,
+		Pattern:    "/payments/",
+		Owner:      mustOwners(t, "@org/payments")[0],
+		Files:      2,
+		Commits:    3,
+		Confidence: 2.0 / 3.0,
+	}, suggestions[2])
+	assert.Equal(t, []string{"untouched/file.txt"}, unsuggested)
+
+	// Without a roster, authors are suggested by email
+	suggestions, _ = SuggestOwners(listing, history, SuggestOptions{MinConfidence: 0.9})
+	patterns = patterns[:0]
+	for _, s := range suggestions {
+		patterns = append(patterns, s.Rule.String())
+	}
+	assert.Equal(t, []string{
+		`/docs/ carol@example.com`,
+		`/mixed/unowned.go carol@example.com`,
+		`/search/index.go carol@example.com`,
+		`/untouched/ stranger@example.com`,
+	}, patterns)
+}
+
+func TestSuggestPattern(t *testing.T) {
+	assert.Equal(t, "/docs/", suggestPattern("docs", true))
+	assert.Equal(t, `/docs/with\ space\*.md`, suggestPattern("docs/with space*.md", false))
+}
+
+func TestParseRosterErrors(t *testing.T) {
+	_, err := ParseRoster(strings.NewReader("@org/a alice@example.com\n@org/b not-an-email\n"))
+	assert.EqualError(t, err, "line 2: invalid email 'not-an-email'")
+
+	_, err = ParseRoster(strings.NewReader("@org/a alice@example.com\n@org/b alice@example.com\n"))
+	assert.EqualError(t, err, "line 2: alice@example.com is already a member of @org/a")
+}
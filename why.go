@@ -0,0 +1,50 @@
+package codeowners
+
+// RuleMatch describes the rule that determines the ownership of a single file. Unowned files have
+// a Line of -1, a nil Rule and nil Owners.
+type RuleMatch struct {
+	Path string `json:"path"`
+	// File is the file the rule came from, for rules composed from several files.
+	File   string  `json:"file,omitempty"`
+	Line   int     `json:"line"`
+	Rule   *string `json:"rule"`
+	Owners []Owner `json:"owners"`
+}
+
+// ExplainOwnership returns the rule that takes effect for the path, which is the last one to match.
+func ExplainOwnership(rules Ruleset, path string) (RuleMatch, error) {
+	match := RuleMatch{Path: path, Line: -1}
+
+	rule, err := rules.Match(path)
+	if err != nil || rule == nil {
+		return match, err
+	}
+
+	return ruleMatch(path, rule), nil
+}
+
+// TraceOwnership returns every rule matching the path, in order. The last one takes effect.
+func TraceOwnership(rules Ruleset, path string) ([]RuleMatch, error) {
+	matches := make([]RuleMatch, 0)
+	for i := range rules {
+		ok, err := rules[i].Match(path)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, ruleMatch(path, &rules[i]))
+		}
+	}
+	return matches, nil
+}
+
+func ruleMatch(path string, rule *Rule) RuleMatch {
+	pattern := rule.RawPattern()
+	return RuleMatch{
+		Path:   path,
+		File:   rule.SourceFile,
+		Line:   rule.SourceLine,
+		Rule:   &pattern,
+		Owners: rule.Owners,
+	}
+}
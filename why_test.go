@@ -0,0 +1,29 @@
+package codeowners
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainOwnership(t *testing.T) {
+	rules := mustParse(t, `* @default
+*.go @gophers
+/vendor/
+`)
+
+	pattern := "*.go"
+	match, err := ExplainOwnership(rules, "cmd/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, RuleMatch{Path: "cmd/main.go", Line: 2, Rule: &pattern, Owners: mustOwners(t, "@gophers")}, match)
+
+	pattern = "/vendor/"
+	match, err = ExplainOwnership(rules, "vendor/lib.go")
+	require.NoError(t, err)
+	assert.Equal(t, RuleMatch{Path: "vendor/lib.go", Line: 3, Rule: &pattern, Owners: []Owner{}}, match)
+
+	match, err = ExplainOwnership(Ruleset{}, "README.md")
+	require.NoError(t, err)
+	assert.Equal(t, RuleMatch{Path: "README.md", Line: -1}, match)
+}